@@ -0,0 +1,215 @@
+// Package agent collects the resource usage of the cluster machines from /proc
+// and pushes it to the nomi observer
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/nomi/definition"
+	"github.com/giantswarm/nomi/log"
+	"github.com/giantswarm/nomi/samples"
+	"github.com/giantswarm/nomi/tlspin"
+)
+
+// Source is the name the agents push their samples under
+const Source = "agent"
+
+const pushTimeout = 10 * time.Second
+
+type Config struct {
+	ObserverAddr string
+	Hostname     string
+	Monitor      definition.Monitor
+	ProcRoot     string
+
+	// Token authenticates the samples pushed to the observer, which is reached
+	// over https when PinnedPubKey is set
+	Token        string
+	PinnedPubKey string
+}
+
+// Agent samples the monitored processes of a machine. The CPU usage and the
+// context switches are computed from the counters of the previous sample.
+type Agent struct {
+	config Config
+	proc   procFS
+	client *http.Client
+
+	// countUnits returns the number of systemd units of the machine
+	countUnits func() (int, error)
+
+	previous     map[int]processCounters
+	previousTime time.Time
+}
+
+func New(config Config) *Agent {
+	if config.ProcRoot == "" {
+		config.ProcRoot = "/proc"
+	}
+	config.Monitor = config.Monitor.WithDefaults()
+	return &Agent{
+		config:     config,
+		proc:       procFS{root: config.ProcRoot},
+		client:     newClient(config),
+		countUnits: countSystemdUnits,
+		previous:   map[int]processCounters{},
+	}
+}
+
+// Run pushes a sample to the observer every interval, forever
+func (a *Agent) Run() {
+	interval := time.Duration(a.config.Monitor.Interval) * time.Second
+	// the first sample only sets the counters the next one is computed from
+	a.Sample(time.Now())
+	for now := range time.Tick(interval) {
+		if err := a.push(a.Sample(now)); err != nil {
+			log.Logger().Warningf("unable to push the samples to the observer: %v", err)
+		}
+	}
+}
+
+// Sample reads the current usage of the host and of the monitored processes.
+// When several processes match a name their usage is summed up.
+func (a *Agent) Sample(now time.Time) []samples.Sample {
+	batch := []samples.Sample{a.sampleHost(now)}
+
+	elapsed := now.Sub(a.previousTime).Seconds()
+	current := map[int]processCounters{}
+	for _, process := range a.config.Monitor.Processes {
+		pids := []int{process.PID}
+		if process.PID == 0 {
+			var err error
+			if pids, err = a.proc.findPIDs(process.Name); err != nil {
+				log.Logger().Warningf("unable to look up the process %s: %v", process.Name, err)
+			}
+		}
+
+		var found, rss, threads, fds, cpuTicks, voluntary, nonvoluntary float64
+		for _, pid := range pids {
+			counters, err := a.proc.readProcess(pid)
+			if err != nil {
+				continue
+			}
+			current[pid] = counters
+			found++
+			rss += float64(counters.rss)
+			threads += float64(counters.threads)
+			fds += float64(counters.fds)
+
+			if previous, known := a.previous[pid]; known {
+				cpuTicks += float64(counters.cpuTicks) - float64(previous.cpuTicks)
+				voluntary += float64(counters.voluntaryCtxSwitches - previous.voluntaryCtxSwitches)
+				nonvoluntary += float64(counters.nonvoluntaryCtxSwitches - previous.nonvoluntaryCtxSwitches)
+			}
+		}
+
+		cpuUsage := 0.0
+		if elapsed > 0 {
+			cpuUsage = cpuTicks / clockTicks / elapsed * 100
+		}
+		batch = append(batch, samples.Sample{
+			Host:      a.config.Hostname,
+			Process:   process.Name,
+			Timestamp: now,
+			Metrics: map[string]float64{
+				samples.Processes:               found,
+				samples.CPUUsage:                cpuUsage,
+				samples.RSS:                     rss,
+				samples.Threads:                 threads,
+				samples.FDs:                     fds,
+				samples.VoluntaryCtxSwitches:    voluntary,
+				samples.NonvoluntaryCtxSwitches: nonvoluntary,
+			},
+		})
+	}
+
+	a.previous = current
+	a.previousTime = now
+	return batch
+}
+
+func (a *Agent) sampleHost(now time.Time) samples.Sample {
+	metrics := map[string]float64{}
+	if load1, load5, load15, err := a.proc.readLoad(); err == nil {
+		metrics[samples.Load1] = load1
+		metrics[samples.Load5] = load5
+		metrics[samples.Load15] = load15
+	} else {
+		log.Logger().Warningf("unable to read the load of the host: %v", err)
+	}
+	if total, available, err := a.proc.readMemory(); err == nil {
+		metrics[samples.MemTotal] = float64(total)
+		metrics[samples.MemAvailable] = float64(available)
+	} else {
+		log.Logger().Warningf("unable to read the memory of the host: %v", err)
+	}
+	if units, err := a.countUnits(); err == nil {
+		metrics[samples.SystemdUnits] = float64(units)
+	} else {
+		log.Logger().Warningf("unable to count the systemd units: %v", err)
+	}
+	return samples.Sample{Host: a.config.Hostname, Timestamp: now, Metrics: metrics}
+}
+
+func (a *Agent) push(batch []samples.Sample) error {
+	body, err := json.Marshal(samples.Batch{Samples: batch})
+	if err != nil {
+		return err
+	}
+	resp, err := a.client.Post(observerURL(a.config, samples.PathPrefix+Source, nil), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("observer answered %s", resp.Status)
+	}
+	return nil
+}
+
+// newClient returns the client to reach the observer with, pinning its public
+// key over https
+func newClient(config Config) *http.Client {
+	if config.PinnedPubKey != "" {
+		return tlspin.Client(config.PinnedPubKey, pushTimeout)
+	}
+	return &http.Client{Timeout: pushTimeout}
+}
+
+// observerURL returns the URL of an observer endpoint, with the callback token
+// added to the query
+func observerURL(config Config, path string, query url.Values) string {
+	scheme := "http"
+	if config.PinnedPubKey != "" {
+		scheme = "https"
+	}
+	if query == nil {
+		query = url.Values{}
+	}
+	if config.Token != "" {
+		query.Set("token", config.Token)
+	}
+	u := url.URL{Scheme: scheme, Host: config.ObserverAddr, Path: path, RawQuery: query.Encode()}
+	return u.String()
+}
+
+func countSystemdUnits() (int, error) {
+	out, err := exec.Command("systemctl", "list-units", "--all", "--no-legend", "--no-pager").Output()
+	if err != nil {
+		return 0, err
+	}
+	units := 0
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.TrimSpace(line) != "" {
+			units++
+		}
+	}
+	return units, nil
+}
@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/nomi/definition"
+	"github.com/giantswarm/nomi/samples"
+)
+
+func writeProcFile(root, path, content string) {
+	path = filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Fatalf("unable to create %s: %v", path, err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		log.Fatalf("unable to write %s: %v", path, err)
+	}
+}
+
+func writeProcess(root, pid, comm string, utime, stime, voluntary int) {
+	writeProcFile(root, pid+"/comm", comm+"\n")
+	writeProcFile(root, pid+"/stat", pid+" ("+comm+" x) S 1 1 1 0 -1 4194560 100 0 0 0 "+
+		strconv.Itoa(utime)+" "+strconv.Itoa(stime)+" 0 0 20 0 3 0 10 1000 200\n")
+	writeProcFile(root, pid+"/status", "Name:\t"+comm+"\nVmRSS:\t    2048 kB\nThreads:\t3\n"+
+		"voluntary_ctxt_switches:\t"+strconv.Itoa(voluntary)+"\nnonvoluntary_ctxt_switches:\t7\n")
+	writeProcFile(root, pid+"/fd/0", "")
+	writeProcFile(root, pid+"/fd/1", "")
+}
+
+func TestSample(t *testing.T) {
+	root, err := ioutil.TempDir("", "nomi-proc")
+	if err != nil {
+		log.Fatalf("unable to create the proc tree: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	writeProcFile(root, "loadavg", "0.50 0.25 0.10 2/75 6188\n")
+	writeProcFile(root, "meminfo", "MemTotal:        6158152 kB\nMemFree:         5245576 kB\nMemAvailable:    5688144 kB\n")
+	writeProcess(root, "1", "systemd", 10, 10, 100)
+	writeProcess(root, "42", "fleetd", 100, 50, 10)
+	writeProcess(root, "43", "fleetd", 0, 0, 10)
+
+	agent := New(Config{
+		Hostname: "host-1",
+		Monitor: definition.Monitor{
+			Interval:  10,
+			Processes: []definition.MonitoredProcess{{Name: "fleetd"}, {Name: "systemd", PID: 1}},
+		},
+		ProcRoot: root,
+	})
+	agent.countUnits = func() (int, error) { return 12, nil }
+
+	start := time.Now()
+	agent.Sample(start)
+
+	writeProcess(root, "42", "fleetd", 200, 100, 15)
+	batch := agent.Sample(start.Add(10 * time.Second))
+
+	if len(batch) != 3 {
+		log.Fatalf("expected a host and 2 process samples, got: %+v", batch)
+	}
+
+	host := batch[0]
+	if host.Host != "host-1" || host.Process != "" || host.Metrics[samples.Load1] != 0.5 || host.Metrics[samples.Load15] != 0.1 ||
+		host.Metrics[samples.MemTotal] != 6158152 || host.Metrics[samples.MemAvailable] != 5688144 || host.Metrics[samples.SystemdUnits] != 12 {
+		log.Fatalf("wrong host sample, got: %+v", host)
+	}
+
+	fleetd := batch[1]
+	// 150 ticks in 10 seconds
+	if fleetd.Process != "fleetd" || fleetd.Metrics[samples.Processes] != 2 || fleetd.Metrics[samples.CPUUsage] != 15 ||
+		fleetd.Metrics[samples.RSS] != 4096 || fleetd.Metrics[samples.Threads] != 6 || fleetd.Metrics[samples.FDs] != 4 ||
+		fleetd.Metrics[samples.VoluntaryCtxSwitches] != 5 || fleetd.Metrics[samples.NonvoluntaryCtxSwitches] != 0 {
+		log.Fatalf("wrong fleetd sample, got: %+v", fleetd)
+	}
+	systemd := batch[2]
+	if systemd.Process != "systemd" || systemd.Metrics[samples.Processes] != 1 || systemd.Metrics[samples.CPUUsage] != 0 ||
+		systemd.Metrics[samples.RSS] != 2048 || !systemd.Timestamp.Equal(start.Add(10*time.Second)) {
+		log.Fatalf("wrong systemd sample, got: %+v", systemd)
+	}
+}
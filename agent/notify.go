@@ -0,0 +1,22 @@
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Notify reports an event of an instance group to the observer, hello when it
+// started or bye when it stopped, like the curl notifier of the units does
+func Notify(config Config, event, unitID, machineID string) error {
+	query := url.Values{"host": {config.Hostname}, "machine": {machineID}}
+	resp, err := newClient(config).Get(observerURL(config, "/"+event+"/"+unitID, query))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("observer answered %s", resp.Status)
+	}
+	return nil
+}
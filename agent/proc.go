@@ -0,0 +1,156 @@
+package agent
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// clockTicks is the USER_HZ the kernel reports the CPU times of /proc in, 100
+// on every architecture nomi runs on
+const clockTicks = 100
+
+// processCounters are the values of a process read from /proc/[pid]
+type processCounters struct {
+	pid                     int
+	cpuTicks                uint64
+	rss                     int
+	threads                 int
+	fds                     int
+	voluntaryCtxSwitches    int64
+	nonvoluntaryCtxSwitches int64
+}
+
+// procFS reads the process and host statistics from a /proc tree
+type procFS struct {
+	root string
+}
+
+func (p procFS) path(elems ...string) string {
+	return filepath.Join(append([]string{p.root}, elems...)...)
+}
+
+// findPIDs returns the processes whose command name contains name, like
+// pidstat -C does
+func (p procFS) findPIDs(name string) ([]int, error) {
+	entries, err := ioutil.ReadDir(p.root)
+	if err != nil {
+		return nil, err
+	}
+
+	pids := []int{}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		comm, err := ioutil.ReadFile(p.path(entry.Name(), "comm"))
+		if err != nil {
+			// the process exited meanwhile
+			continue
+		}
+		if strings.Contains(strings.TrimSpace(string(comm)), name) {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// readProcess reads the counters of a process
+func (p procFS) readProcess(pid int) (processCounters, error) {
+	counters := processCounters{pid: pid}
+	dir := strconv.Itoa(pid)
+
+	stat, err := ioutil.ReadFile(p.path(dir, "stat"))
+	if err != nil {
+		return counters, err
+	}
+	// the command name may contain spaces, the fields start after it
+	line := string(stat)
+	end := strings.LastIndex(line, ")")
+	if end < 0 {
+		return counters, fmt.Errorf("malformed stat of process %d", pid)
+	}
+	fields := strings.Fields(line[end+1:])
+	// utime and stime are the 14th and 15th fields, the fields start at the
+	// 3rd one
+	if len(fields) < 13 {
+		return counters, fmt.Errorf("malformed stat of process %d", pid)
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return counters, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return counters, err
+	}
+	counters.cpuTicks = utime + stime
+
+	status, err := p.readKeyValues(p.path(dir, "status"))
+	if err != nil {
+		return counters, err
+	}
+	counters.rss = int(status["VmRSS"])
+	counters.threads = int(status["Threads"])
+	counters.voluntaryCtxSwitches = status["voluntary_ctxt_switches"]
+	counters.nonvoluntaryCtxSwitches = status["nonvoluntary_ctxt_switches"]
+
+	// reading the file descriptors of other users' processes requires root
+	if fds, err := ioutil.ReadDir(p.path(dir, "fd")); err == nil {
+		counters.fds = len(fds)
+	}
+
+	return counters, nil
+}
+
+// readLoad reads the load averages of the host
+func (p procFS) readLoad() (load1, load5, load15 float64, err error) {
+	content, err := ioutil.ReadFile(p.path("loadavg"))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	_, err = fmt.Sscanf(string(content), "%f %f %f", &load1, &load5, &load15)
+	return load1, load5, load15, err
+}
+
+// readMemory reads the total and the available memory of the host in kB
+func (p procFS) readMemory() (total, available int, err error) {
+	meminfo, err := p.readKeyValues(p.path("meminfo"))
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(meminfo["MemTotal"]), int(meminfo["MemAvailable"]), nil
+}
+
+// readKeyValues parses the "Key: value [kB]" lines of the files of /proc
+func (p procFS) readKeyValues(path string) (map[string]int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := map[string]int64{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fields := strings.Fields(parts[1])
+		if len(fields) == 0 {
+			continue
+		}
+		value, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[strings.TrimSpace(parts[0])] = value
+	}
+	return values, scanner.Err()
+}
@@ -0,0 +1,55 @@
+// This backend package defines the operations nomi performs against the
+// scheduler under benchmark, so the same definitions, instructions and reports
+// can be used with schedulers other than fleet
+package backend
+
+import (
+	"net"
+
+	"github.com/coreos/fleet/schema"
+)
+
+// Backend represents all the operations we want to perform to a scheduler
+type Backend interface {
+	StartUnit(unit schema.Unit) error
+	StartUnitGroup(units []schema.Unit) error
+	ListUnits() ([]*schema.Unit, error)
+	CleanupPrefix(prefix string) error
+	Stop(unitName string) error
+	Destroy(unitName string) error
+}
+
+// UnitStateLister is implemented by backends that are able to report the state
+// of the units as seen by the init system of the hosts
+type UnitStateLister interface {
+	UnitStates() ([]*schema.UnitState, error)
+}
+
+// Poller is implemented by backends that record the requests they send to the
+// scheduler. Poller returns a client of the same scheduler for the requests
+// nomi sends periodically on its own, which are not recorded.
+type Poller interface {
+	Poller() Backend
+}
+
+// Dialer is implemented by backends that reach the cluster in a way of their
+// own, e.g. through a SSH tunnel. Dial connects to other services of the
+// cluster the same way.
+type Dialer interface {
+	Dial(network, addr string) (net.Conn, error)
+}
+
+// Machine describes a host of the cluster able to run units
+type Machine struct {
+	ID       string
+	PublicIP string
+	Metadata map[string]string
+	// Version of the scheduler agent running on the machine, if known
+	Version string
+}
+
+// MachineLister is implemented by backends that are able to list the machines
+// of the cluster
+type MachineLister interface {
+	Machines() ([]Machine, error)
+}
@@ -0,0 +1,498 @@
+// This local package implements a backend that runs the benchmark units as
+// child processes of nomi, so benchmarks can be tried out without a fleet
+// cluster, etcd or systemd
+package local
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/coreos/fleet/schema"
+
+	"github.com/giantswarm/nomi/backend"
+	"github.com/giantswarm/nomi/log"
+)
+
+const (
+	machineIDFile = "/etc/machine-id"
+
+	defaultStopTimeout = 10 * time.Second
+
+	stateActivating   = "activating"
+	stateActive       = "active"
+	stateDeactivating = "deactivating"
+	stateInactive     = "inactive"
+	stateFailed       = "failed"
+)
+
+var errUnitExists = errors.New("unit already exists")
+
+type localBackend struct {
+	hostname  string
+	machineID string
+
+	units map[string]*localUnit
+	mu    *sync.Mutex
+}
+
+type localUnit struct {
+	unit schema.Unit
+
+	targetState string
+	activeState string
+	main        *exec.Cmd
+	exited      chan struct{}
+
+	// The ExecStartPre command in progress, killed when the unit is stopped
+	// before it is active. activated is closed once the start is over.
+	pre         *exec.Cmd
+	cancelStart bool
+	activated   chan struct{}
+
+	mu *sync.Mutex
+}
+
+// NewLocalBackend creates a backend that runs the units on the local host
+func NewLocalBackend() backend.Backend {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+
+	machineID := "local"
+	if content, err := ioutil.ReadFile(machineIDFile); err == nil && len(strings.TrimSpace(string(content))) > 0 {
+		machineID = strings.TrimSpace(string(content))
+	}
+
+	return &localBackend{
+		hostname:  hostname,
+		machineID: machineID,
+		units:     map[string]*localUnit{},
+		mu:        new(sync.Mutex),
+	}
+}
+
+// StartUnit starts a specific unit on the local host
+func (b *localBackend) StartUnit(unit schema.Unit) error {
+	return b.StartUnitGroup([]schema.Unit{unit})
+}
+
+// StartUnitGroup starts an instance group honouring the Before/After ordering
+// between its units
+func (b *localBackend) StartUnitGroup(units []schema.Unit) error {
+	b.mu.Lock()
+	for _, unit := range units {
+		if _, exists := b.units[unit.Name]; exists {
+			b.mu.Unlock()
+			return fmt.Errorf("%s: %v", unit.Name, errUnitExists)
+		}
+	}
+	group := []*localUnit{}
+	for _, unit := range orderUnits(units) {
+		u := &localUnit{
+			unit:        unit,
+			targetState: "launched",
+			activeState: stateInactive,
+			activated:   make(chan struct{}),
+			mu:          new(sync.Mutex),
+		}
+		b.units[unit.Name] = u
+		group = append(group, u)
+	}
+	b.mu.Unlock()
+
+	go func() {
+		for _, u := range group {
+			if err := b.start(u); err != nil {
+				log.Logger().Warningf("unable to start unit %s: %v", u.unit.Name, err)
+			}
+		}
+	}()
+	return nil
+}
+
+// ListUnits returns the list of units known by the local backend
+func (b *localBackend) ListUnits() ([]*schema.Unit, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	units := []*schema.Unit{}
+	for _, u := range b.units {
+		u.mu.Lock()
+		current := "loaded"
+		if u.activeState != stateInactive && u.activeState != stateFailed {
+			current = "launched"
+		}
+		units = append(units, &schema.Unit{
+			Name:         u.unit.Name,
+			Options:      u.unit.Options,
+			DesiredState: u.targetState,
+			CurrentState: current,
+			MachineID:    b.machineID,
+		})
+		u.mu.Unlock()
+	}
+	return units, nil
+}
+
+// Machines returns the host running the units as the only machine
+func (b *localBackend) Machines() ([]backend.Machine, error) {
+	return []backend.Machine{{
+		ID:       b.machineID,
+		Metadata: map[string]string{"hostname": b.hostname},
+	}}, nil
+}
+
+// UnitStates returns the systemd like states of the units
+func (b *localBackend) UnitStates() ([]*schema.UnitState, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	states := []*schema.UnitState{}
+	for _, u := range b.units {
+		u.mu.Lock()
+		subState := "dead"
+		if u.activeState == stateActive {
+			subState = "running"
+		}
+		states = append(states, &schema.UnitState{
+			Name:               u.unit.Name,
+			MachineID:          b.machineID,
+			SystemdLoadState:   "loaded",
+			SystemdActiveState: u.activeState,
+			SystemdSubState:    subState,
+		})
+		u.mu.Unlock()
+	}
+	return states, nil
+}
+
+// CleanupPrefix destroys all units with a specific prefix
+func (b *localBackend) CleanupPrefix(prefix string) error {
+	b.mu.Lock()
+	names := []string{}
+	for name := range b.units {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, name := range names {
+		b.Destroy(name)
+	}
+	return nil
+}
+
+// Stop stops an unit by passing its unit name. Units bound to it are stopped
+// as well.
+func (b *localBackend) Stop(unitName string) error {
+	u, err := b.getUnit(unitName)
+	if err != nil {
+		return err
+	}
+	u.mu.Lock()
+	u.targetState = "loaded"
+	u.mu.Unlock()
+
+	go b.stop(u)
+	return nil
+}
+
+// Destroy stops an unit and removes it from the local backend
+func (b *localBackend) Destroy(unitName string) error {
+	u, err := b.getUnit(unitName)
+	if err != nil {
+		return err
+	}
+	u.mu.Lock()
+	u.targetState = "inactive"
+	u.mu.Unlock()
+	b.stop(u)
+
+	b.mu.Lock()
+	delete(b.units, unitName)
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *localBackend) getUnit(unitName string) (*localUnit, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	u, exists := b.units[unitName]
+	if !exists {
+		return nil, fmt.Errorf("unit %s not found", unitName)
+	}
+	return u, nil
+}
+
+// boundTo returns the units which have a BindTo dependency on the given unit
+func (b *localBackend) boundTo(unitName string) []*localUnit {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bound := []*localUnit{}
+	for _, u := range b.units {
+		for _, dep := range unitOptions(u.unit, "Unit", "BindTo", "BindsTo") {
+			if dep == unitName {
+				bound = append(bound, u)
+			}
+		}
+	}
+	return bound
+}
+
+// transition moves the unit to a new state if it currently is in one of the
+// given states
+func (u *localUnit) transition(to string, from ...string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, state := range from {
+		if u.activeState == state {
+			u.activeState = to
+			return true
+		}
+	}
+	return false
+}
+
+// errStartCancelled is returned by the commands which were not run because the
+// unit was stopped while it was activating
+var errStartCancelled = errors.New("start cancelled")
+
+func (b *localBackend) start(u *localUnit) error {
+	defer close(u.activated)
+	if !u.transition(stateActivating, stateInactive, stateFailed) {
+		return nil
+	}
+
+	for _, line := range unitOptions(u.unit, "Service", "ExecStartPre") {
+		if err := b.executeStartPre(u, line); err != nil {
+			return b.failStart(u, err)
+		}
+	}
+
+	lines := unitOptions(u.unit, "Service", "ExecStart")
+	if len(lines) == 0 {
+		return b.failStart(u, errors.New("no ExecStart defined"))
+	}
+	cmd, _, err := b.command(u, lines[0])
+	if err != nil {
+		return b.failStart(u, err)
+	}
+
+	// the check and the start of the main process happen under the lock, so
+	// that a stop either cancels the start or finds the unit active
+	u.mu.Lock()
+	if u.startCancelled() {
+		u.mu.Unlock()
+		return b.failStart(u, errStartCancelled)
+	}
+	if err := cmd.Start(); err != nil {
+		u.mu.Unlock()
+		return b.failStart(u, err)
+	}
+	exited := make(chan struct{})
+	u.main = cmd
+	u.exited = exited
+	u.activeState = stateActive
+	u.mu.Unlock()
+
+	go func() {
+		cmd.Wait()
+		close(exited)
+		// The main process finished on its own, so the unit goes down
+		if u.transition(stateDeactivating, stateActive) {
+			b.deactivate(u)
+		}
+	}()
+	return nil
+}
+
+// executeStartPre runs an ExecStartPre command unless the start was cancelled
+func (b *localBackend) executeStartPre(u *localUnit, line string) error {
+	cmd, ignoreFailure, err := b.command(u, line)
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	if u.startCancelled() {
+		u.mu.Unlock()
+		return errStartCancelled
+	}
+	err = cmd.Start()
+	if err == nil {
+		u.pre = cmd
+	}
+	u.mu.Unlock()
+	if err == nil {
+		err = cmd.Wait()
+	}
+
+	u.mu.Lock()
+	u.pre = nil
+	cancelled := u.startCancelled()
+	u.mu.Unlock()
+	if cancelled {
+		return errStartCancelled
+	}
+	if err != nil && !ignoreFailure {
+		return fmt.Errorf("%s: %v", line, err)
+	}
+	return nil
+}
+
+// startCancelled tells whether the unit was stopped or destroyed while it was
+// activating, the caller holds the lock
+func (u *localUnit) startCancelled() bool {
+	return u.cancelStart || u.targetState != "launched"
+}
+
+// failStart ends a start which did not get to the main process. A cancelled
+// start goes down like a stopped unit, running its post stop commands.
+func (b *localBackend) failStart(u *localUnit, err error) error {
+	if err != errStartCancelled {
+		u.transition(stateFailed, stateActivating)
+		return err
+	}
+	if u.transition(stateDeactivating, stateActivating) {
+		b.deactivate(u)
+	}
+	return nil
+}
+
+func (b *localBackend) stop(u *localUnit) {
+	// A unit still activating gives up its start: its ExecStartPre command is
+	// killed and the stop waits for the start to be over
+	u.mu.Lock()
+	activating := u.activeState == stateActivating
+	if activating {
+		u.cancelStart = true
+		signalProcessGroup(u.pre, syscall.SIGKILL)
+	}
+	u.mu.Unlock()
+	if activating {
+		<-u.activated
+		return
+	}
+
+	if !u.transition(stateDeactivating, stateActive) {
+		return
+	}
+
+	for _, line := range unitOptions(u.unit, "Service", "ExecStop") {
+		if err := b.execute(u, line); err != nil {
+			log.Logger().Warningf("unit %s: %v", u.unit.Name, err)
+		}
+	}
+
+	u.mu.Lock()
+	main := u.main
+	exited := u.exited
+	u.mu.Unlock()
+
+	signalProcessGroup(main, killSignal(u.unit))
+	select {
+	case <-exited:
+	case <-time.After(stopTimeout(u.unit)):
+		signalProcessGroup(main, syscall.SIGKILL)
+		<-exited
+	}
+
+	b.deactivate(u)
+}
+
+// deactivate runs the post stop commands of an unit whose main process is gone
+// and stops all the units bound to it
+func (b *localBackend) deactivate(u *localUnit) {
+	for _, line := range unitOptions(u.unit, "Service", "ExecStopPost") {
+		if err := b.execute(u, line); err != nil {
+			log.Logger().Warningf("unit %s: %v", u.unit.Name, err)
+		}
+	}
+	u.transition(stateInactive, stateDeactivating)
+
+	wg := new(sync.WaitGroup)
+	for _, bound := range b.boundTo(u.unit.Name) {
+		wg.Add(1)
+		go func(bound *localUnit) {
+			b.stop(bound)
+			wg.Done()
+		}(bound)
+	}
+	wg.Wait()
+}
+
+// execute runs a command line until it finishes. Failures are ignored when the
+// line is prefixed with '-' as systemd does.
+func (b *localBackend) execute(u *localUnit, line string) error {
+	cmd, ignoreFailure, err := b.command(u, line)
+	if err != nil {
+		return err
+	}
+	err = cmd.Run()
+	if err != nil && !ignoreFailure {
+		return fmt.Errorf("%s: %v", line, err)
+	}
+	return nil
+}
+
+func (b *localBackend) command(u *localUnit, line string) (*exec.Cmd, bool, error) {
+	ignoreFailure := false
+	line = strings.TrimSpace(line)
+	for len(line) > 0 && strings.ContainsAny(line[:1], "-@+!") {
+		if line[0] == '-' {
+			ignoreFailure = true
+		}
+		line = line[1:]
+	}
+
+	specifiers := b.specifiers(u.unit.Name)
+	args, err := splitCommandLine(expandSpecifiers(line, specifiers))
+	if err != nil {
+		return nil, ignoreFailure, err
+	}
+	if len(args) == 0 {
+		return nil, ignoreFailure, errors.New("empty command line")
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), unitEnvironment(u.unit, specifiers)...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd, ignoreFailure, nil
+}
+
+func (b *localBackend) specifiers(unitName string) map[byte]string {
+	name := strings.TrimSuffix(unitName, ".service")
+	prefix, instance := name, ""
+	if at := strings.Index(name, "@"); at >= 0 {
+		prefix, instance = name[:at], name[at+1:]
+	}
+	return map[byte]string{
+		'n': unitName,
+		'N': name,
+		'p': prefix,
+		'i': instance,
+		'H': b.hostname,
+		'm': b.machineID,
+		'%': "%",
+	}
+}
+
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) {
+	if cmd == nil || cmd.Process == nil {
+		return
+	}
+	// The command runs in its own process group, so we signal all its children
+	if err := syscall.Kill(-cmd.Process.Pid, sig); err != nil {
+		cmd.Process.Signal(sig)
+	}
+}
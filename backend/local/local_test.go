@@ -0,0 +1,163 @@
+package local
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/fleet/schema"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	words, err := splitCommandLine(`/bin/bash -c 'echo \'{print $7 " " $12}\'' "a b"`)
+	if err != nil {
+		log.Fatalf("unable to split the command line: %v", err)
+	}
+	expected := []string{"/bin/bash", "-c", `echo '{print $7 " " $12}'`, "a b"}
+	if !reflect.DeepEqual(words, expected) {
+		log.Fatalf("wrong words expected %q got: %q", expected, words)
+	}
+
+	if _, err := splitCommandLine(`/bin/sh -c 'sleep 1`); err == nil {
+		log.Fatal("expected an error for an unterminated quote")
+	}
+}
+
+func TestExpandSpecifiers(t *testing.T) {
+	b := &localBackend{hostname: "host1", machineID: "abc"}
+	expanded := expandSpecifiers("%p-%i %n %H %m 100%% $$HOME", b.specifiers("nomi-0@f00.service"))
+	if expanded != "nomi-0-f00 nomi-0@f00.service host1 abc 100% $HOME" {
+		log.Fatalf("wrong expansion got: %s", expanded)
+	}
+}
+
+func TestUnitGroupOrdering(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nomi-local")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "log")
+
+	makeUnit := func(name string, before string) schema.Unit {
+		unit := schema.Unit{
+			Name: name,
+			Options: []*schema.UnitOption{
+				{Section: "Service", Name: "ExecStartPre", Value: "/bin/sh -c 'echo start %p >> " + logFile + "'"},
+				{Section: "Service", Name: "ExecStart", Value: "/bin/sh -c 'sleep 60'"},
+				{Section: "Service", Name: "ExecStopPost", Value: "/bin/sh -c 'echo stop %p >> " + logFile + "'"},
+			},
+		}
+		if before != "" {
+			unit.Options = append(unit.Options,
+				&schema.UnitOption{Section: "Unit", Name: "Before", Value: before},
+				&schema.UnitOption{Section: "Unit", Name: "BindTo", Value: before},
+			)
+		}
+		return unit
+	}
+
+	b := NewLocalBackend()
+	// the chain is handed over in reverse order on purpose
+	err = b.StartUnitGroup([]schema.Unit{
+		makeUnit("test-0@1.service", ""),
+		makeUnit("test-1@1.service", "test-0@1.service"),
+	})
+	if err != nil {
+		log.Fatalf("unable to start the unit group: %v", err)
+	}
+
+	waitForLines(logFile, 2)
+	if err := b.Stop("test-0@1.service"); err != nil {
+		log.Fatalf("unable to stop the unit: %v", err)
+	}
+	lines := waitForLines(logFile, 4)
+
+	expected := []string{"start test-1", "start test-0", "stop test-0", "stop test-1"}
+	if !reflect.DeepEqual(lines, expected) {
+		log.Fatalf("wrong execution order expected %q got: %q", expected, lines)
+	}
+
+	b.CleanupPrefix("test-")
+	units, _ := b.ListUnits()
+	if len(units) != 0 {
+		log.Fatalf("expected no units after cleanup got: %d", len(units))
+	}
+}
+
+func waitForLines(path string, n int) []string {
+	timeout := time.After(10 * time.Second)
+	for {
+		content, _ := ioutil.ReadFile(path)
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		if len(content) > 0 && len(lines) >= n {
+			return lines
+		}
+		select {
+		case <-timeout:
+			log.Fatalf("timeout waiting for %d lines in %s", n, path)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func TestStopWhileActivating(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nomi-local")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "log")
+
+	makeUnit := func(name string) schema.Unit {
+		return schema.Unit{
+			Name: name,
+			Options: []*schema.UnitOption{
+				{Section: "Service", Name: "ExecStartPre", Value: "/bin/sh -c 'echo pre %p >> " + logFile + " && sleep 60'"},
+				{Section: "Service", Name: "ExecStart", Value: "/bin/sh -c 'echo start %p >> " + logFile + " && sleep 60'"},
+				{Section: "Service", Name: "ExecStopPost", Value: "/bin/sh -c 'echo stop %p >> " + logFile + "'"},
+			},
+		}
+	}
+
+	b := NewLocalBackend()
+	if err := b.StartUnit(makeUnit("stopped-0@1.service")); err != nil {
+		log.Fatal(err)
+	}
+	if err := b.StartUnit(makeUnit("destroyed-0@1.service")); err != nil {
+		log.Fatal(err)
+	}
+	waitForLines(logFile, 2)
+
+	if err := b.Stop("stopped-0@1.service"); err != nil {
+		log.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- b.Destroy("destroyed-0@1.service") }()
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		log.Fatalf("the destroy waited for the ExecStartPre command")
+	}
+	waitForLines(logFile, 4)
+
+	// the main processes must never be started
+	time.Sleep(200 * time.Millisecond)
+	content, _ := ioutil.ReadFile(logFile)
+	if strings.Contains(string(content), "start ") {
+		log.Fatalf("a unit stopped while activating was started:\n%s", content)
+	}
+	states, _ := b.(*localBackend).UnitStates()
+	if len(states) != 1 || states[0].SystemdActiveState != stateInactive {
+		log.Fatalf("expected the stopped unit to be inactive and the destroyed one gone, got: %+v", states)
+	}
+	b.CleanupPrefix("stopped-")
+}
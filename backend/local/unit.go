@@ -0,0 +1,190 @@
+package local
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/coreos/fleet/schema"
+)
+
+var signals = map[string]syscall.Signal{
+	"SIGTERM": syscall.SIGTERM,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGINT":  syscall.SIGINT,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGQUIT": syscall.SIGQUIT,
+}
+
+// unitOptions returns the values of the unit options with any of the given
+// names in the given section
+func unitOptions(unit schema.Unit, section string, names ...string) []string {
+	values := []string{}
+	for _, option := range unit.Options {
+		if option.Section != section {
+			continue
+		}
+		for _, name := range names {
+			if option.Name == name {
+				values = append(values, option.Value)
+			}
+		}
+	}
+	return values
+}
+
+// orderUnits sorts the units of a group so every unit comes after the units it
+// has to be started after, according to their Before and After options. The
+// original order is kept for units without ordering dependencies.
+func orderUnits(units []schema.Unit) []schema.Unit {
+	index := map[string]int{}
+	for i, unit := range units {
+		index[unit.Name] = i
+	}
+
+	after := make([][]int, len(units))
+	for i, unit := range units {
+		for _, name := range unitOptions(unit, "Unit", "Before") {
+			if j, ok := index[name]; ok {
+				after[j] = append(after[j], i)
+			}
+		}
+		for _, name := range unitOptions(unit, "Unit", "After") {
+			if j, ok := index[name]; ok {
+				after[i] = append(after[i], j)
+			}
+		}
+	}
+
+	ordered := []schema.Unit{}
+	visited := make([]bool, len(units))
+	var visit func(i int)
+	visit = func(i int) {
+		if visited[i] {
+			return
+		}
+		visited[i] = true
+		for _, j := range after[i] {
+			visit(j)
+		}
+		ordered = append(ordered, units[i])
+	}
+	for i := range units {
+		visit(i)
+	}
+	return ordered
+}
+
+// expandSpecifiers replaces the systemd specifiers (%i, %p, ...) of a command
+// line as well as the escaped dollar signs
+func expandSpecifiers(line string, specifiers map[byte]string) string {
+	expanded := []byte{}
+	for i := 0; i < len(line); i++ {
+		if line[i] == '%' && i+1 < len(line) {
+			if value, ok := specifiers[line[i+1]]; ok {
+				expanded = append(expanded, value...)
+				i++
+				continue
+			}
+		}
+		if line[i] == '$' && i+1 < len(line) && line[i+1] == '$' {
+			i++
+		}
+		expanded = append(expanded, line[i])
+	}
+	return string(expanded)
+}
+
+// splitCommandLine splits a command line into words following the systemd
+// quoting rules: single and double quotes group words and backslash escapes
+// the next character
+func splitCommandLine(line string) ([]string, error) {
+	words := []string{}
+	word := []byte{}
+	inWord := false
+	var quote byte
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line):
+			i++
+			word = append(word, unescape(line[i]))
+			inWord = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			word = append(word, c)
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, string(word))
+				word = []byte{}
+				inWord = false
+			}
+		default:
+			word = append(word, c)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote in command line: " + line)
+	}
+	if inWord {
+		words = append(words, string(word))
+	}
+	return words, nil
+}
+
+func unescape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	}
+	return c
+}
+
+// unitEnvironment returns the variables defined with Environment= options,
+// whose specifiers are expanded like systemd does
+func unitEnvironment(unit schema.Unit, specifiers map[byte]string) []string {
+	env := []string{}
+	for _, line := range unitOptions(unit, "Service", "Environment") {
+		words, err := splitCommandLine(expandSpecifiers(line, specifiers))
+		if err != nil {
+			continue
+		}
+		env = append(env, words...)
+	}
+	return env
+}
+
+func killSignal(unit schema.Unit) syscall.Signal {
+	values := unitOptions(unit, "Service", "KillSignal")
+	if len(values) > 0 {
+		if sig, ok := signals[strings.ToUpper(values[len(values)-1])]; ok {
+			return sig
+		}
+	}
+	return syscall.SIGTERM
+}
+
+func stopTimeout(unit schema.Unit) time.Duration {
+	values := unitOptions(unit, "Service", "TimeoutStopSec")
+	if len(values) == 0 {
+		return defaultStopTimeout
+	}
+	value := values[len(values)-1]
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	return defaultStopTimeout
+}
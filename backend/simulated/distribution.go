@@ -0,0 +1,118 @@
+package simulated
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Distribution produces the latencies of the simulated operations
+type Distribution interface {
+	Sample(r *rand.Rand) time.Duration
+	String() string
+}
+
+type constant struct {
+	value float64
+}
+
+type uniform struct {
+	min, max float64
+}
+
+type normal struct {
+	mean, stddev float64
+}
+
+type exponential struct {
+	mean float64
+}
+
+func (d constant) Sample(r *rand.Rand) time.Duration {
+	return seconds(d.value)
+}
+
+func (d constant) String() string {
+	return fmt.Sprintf("const:%g", d.value)
+}
+
+func (d uniform) Sample(r *rand.Rand) time.Duration {
+	return seconds(d.min + r.Float64()*(d.max-d.min))
+}
+
+func (d uniform) String() string {
+	return fmt.Sprintf("uniform:%g,%g", d.min, d.max)
+}
+
+func (d normal) Sample(r *rand.Rand) time.Duration {
+	return seconds(d.mean + r.NormFloat64()*d.stddev)
+}
+
+func (d normal) String() string {
+	return fmt.Sprintf("normal:%g,%g", d.mean, d.stddev)
+}
+
+func (d exponential) Sample(r *rand.Rand) time.Duration {
+	return seconds(r.ExpFloat64() * d.mean)
+}
+
+func (d exponential) String() string {
+	return fmt.Sprintf("exp:%g", d.mean)
+}
+
+// ParseDistribution creates a distribution out of its specification. Values
+// are expressed in seconds and the supported specifications are:
+//   const:<value>
+//   uniform:<min>,<max>
+//   normal:<mean>,<stddev>
+//   exp:<mean>
+func ParseDistribution(spec string) (Distribution, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("wrong distribution %q, expected <type>:<params>", spec)
+	}
+
+	params := []float64{}
+	for _, param := range strings.Split(parts[1], ",") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(param), 64)
+		if err != nil {
+			return nil, fmt.Errorf("wrong distribution parameter in %q: %v", spec, err)
+		}
+		if value < 0 {
+			return nil, fmt.Errorf("distribution parameters have to be positive in %q", spec)
+		}
+		params = append(params, value)
+	}
+
+	expected := map[string]int{"const": 1, "uniform": 2, "normal": 2, "exp": 1}
+	n, known := expected[parts[0]]
+	if !known {
+		return nil, fmt.Errorf("unknown distribution type %q", parts[0])
+	}
+	if len(params) != n {
+		return nil, fmt.Errorf("distribution %s requires %d parameters", parts[0], n)
+	}
+
+	switch parts[0] {
+	case "const":
+		return constant{params[0]}, nil
+	case "uniform":
+		if params[0] > params[1] {
+			return nil, fmt.Errorf("uniform distribution requires min <= max in %q", spec)
+		}
+		return uniform{params[0], params[1]}, nil
+	case "normal":
+		return normal{params[0], params[1]}, nil
+	default:
+		return exponential{params[0]}, nil
+	}
+}
+
+func seconds(value float64) time.Duration {
+	if value < 0 {
+		value = 0
+	}
+	return time.Duration(value * float64(time.Second))
+}
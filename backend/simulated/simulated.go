@@ -0,0 +1,438 @@
+// This simulated package implements an in-memory backend which models the
+// scheduling and the start/stop latencies of a fleet cluster. Together with a
+// virtual clock it allows to analyse large benchmarks before running them on a
+// real cluster.
+package simulated
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/fleet/schema"
+
+	"github.com/giantswarm/nomi/backend"
+	"github.com/giantswarm/nomi/unit"
+)
+
+// Config describes the simulated cluster
+type Config struct {
+	// Agents is the amount of machines of the cluster
+	Agents int
+	// Capacity is the maximum amount of units running on a machine at the same
+	// time. Zero means unlimited.
+	Capacity int
+
+	ScheduleLatency Distribution
+	StartLatency    Distribution
+	StopLatency     Distribution
+
+	Seed int64
+}
+
+type machine struct {
+	id       string
+	hostname string
+	running  int
+}
+
+type group struct {
+	id           string
+	units        []*simUnit
+	stopNotified bool
+}
+
+type simUnit struct {
+	unit         schema.Unit
+	group        *group
+	machine      *machine
+	desiredState string
+	currentState string
+	active       bool
+	// slot tells whether the unit is taking capacity of its machine
+	slot bool
+}
+
+type simulatedBackend struct {
+	config Config
+	engine *unit.UnitEngine
+	clock  unit.Clock
+
+	machines []*machine
+	units    map[string]*simUnit
+	pending  []*group
+	rand     *rand.Rand
+
+	mu *sync.Mutex
+}
+
+// NewSimulatedBackend creates a backend which simulates a fleet cluster and
+// reports the state changes of the units straight to the engine, as the unit
+// callbacks would do in a real cluster
+func NewSimulatedBackend(config Config, engine *unit.UnitEngine, clock unit.Clock) backend.Backend {
+	if config.Agents <= 0 {
+		config.Agents = 1
+	}
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+
+	machines := make([]*machine, config.Agents)
+	for i := range machines {
+		machines[i] = &machine{
+			id:       fmt.Sprintf("%032x", i+1),
+			hostname: fmt.Sprintf("sim-%d", i+1),
+		}
+	}
+
+	return &simulatedBackend{
+		config:   config,
+		engine:   engine,
+		clock:    clock,
+		machines: machines,
+		units:    map[string]*simUnit{},
+		rand:     rand.New(rand.NewSource(config.Seed)),
+		mu:       new(sync.Mutex),
+	}
+}
+
+// StartUnit starts a specific unit in the simulated cluster
+func (b *simulatedBackend) StartUnit(unit schema.Unit) error {
+	return b.StartUnitGroup([]schema.Unit{unit})
+}
+
+// StartUnitGroup schedules an instance group onto one machine and launches its
+// units in order. The engine is told the group is running once all its units
+// have been launched.
+func (b *simulatedBackend) StartUnitGroup(units []schema.Unit) error {
+	if len(units) == 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, unit := range units {
+		if _, exists := b.units[unit.Name]; exists {
+			return fmt.Errorf("unit %s already exists", unit.Name)
+		}
+	}
+
+	g := &group{id: instanceID(units[0].Name)}
+	for _, unit := range units {
+		u := &simUnit{
+			unit:         unit,
+			group:        g,
+			desiredState: "launched",
+			currentState: "inactive",
+		}
+		// Global units run everywhere and are not part of the benchmark
+		if isGlobal(unit) {
+			u.currentState = "launched"
+			u.active = true
+		}
+		b.units[unit.Name] = u
+		g.units = append(g.units, u)
+	}
+
+	if !g.units[0].active {
+		go b.schedule(g)
+	}
+	return nil
+}
+
+// ListUnits returns the list of units in the simulated cluster
+func (b *simulatedBackend) ListUnits() ([]*schema.Unit, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	units := []*schema.Unit{}
+	for _, u := range b.units {
+		machineID := ""
+		if u.machine != nil {
+			machineID = u.machine.id
+		}
+		units = append(units, &schema.Unit{
+			Name:         u.unit.Name,
+			Options:      u.unit.Options,
+			DesiredState: u.desiredState,
+			CurrentState: u.currentState,
+			MachineID:    machineID,
+		})
+	}
+	return units, nil
+}
+
+// Machines returns the simulated agents
+func (b *simulatedBackend) Machines() ([]backend.Machine, error) {
+	machines := []backend.Machine{}
+	for _, m := range b.machines {
+		machines = append(machines, backend.Machine{
+			ID:       m.id,
+			Metadata: map[string]string{"hostname": m.hostname},
+			Version:  "simulated",
+		})
+	}
+	return machines, nil
+}
+
+// UnitStates returns the simulated systemd states of the scheduled units
+func (b *simulatedBackend) UnitStates() ([]*schema.UnitState, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	states := []*schema.UnitState{}
+	for _, u := range b.units {
+		if u.machine == nil {
+			continue
+		}
+		activeState, subState := "inactive", "dead"
+		if u.active {
+			activeState, subState = "active", "running"
+		}
+		states = append(states, &schema.UnitState{
+			Name:               u.unit.Name,
+			MachineID:          u.machine.id,
+			SystemdLoadState:   "loaded",
+			SystemdActiveState: activeState,
+			SystemdSubState:    subState,
+		})
+	}
+	return states, nil
+}
+
+// CleanupPrefix destroys all units with a specific prefix
+func (b *simulatedBackend) CleanupPrefix(prefix string) error {
+	b.mu.Lock()
+	names := []string{}
+	for name := range b.units {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, name := range names {
+		b.Destroy(name)
+	}
+	return nil
+}
+
+// Stop stops an unit and the units bound to it after the simulated stop latency
+func (b *simulatedBackend) Stop(unitName string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	u, exists := b.units[unitName]
+	if !exists {
+		return fmt.Errorf("unit %s not found", unitName)
+	}
+
+	active := false
+	for _, stopping := range b.boundTo(u) {
+		stopping.desiredState = "loaded"
+		b.removePending(stopping.group)
+		if stopping.active {
+			active = true
+			go b.stopUnit(stopping, b.sample(b.config.StopLatency))
+		} else {
+			b.deactivate(stopping)
+		}
+	}
+	// A group stopped while pending or starting still reports its stop, as
+	// the post stop commands of its units would
+	if !active {
+		go b.notifyStopped(u.group, b.sample(b.config.StopLatency))
+	}
+	b.schedulePending()
+	return nil
+}
+
+// Destroy removes an unit from the simulated cluster releasing its capacity
+func (b *simulatedBackend) Destroy(unitName string) error {
+	b.mu.Lock()
+	u, exists := b.units[unitName]
+	if !exists {
+		b.mu.Unlock()
+		return fmt.Errorf("unit %s not found", unitName)
+	}
+	b.deactivate(u)
+	b.removePending(u.group)
+	delete(b.units, unitName)
+	b.schedulePending()
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *simulatedBackend) schedule(g *group) {
+	b.clock.Sleep(b.lockedSample(b.config.ScheduleLatency))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if g.units[0].desiredState != "launched" {
+		return
+	}
+	if !b.place(g) {
+		b.pending = append(b.pending, g)
+	}
+}
+
+// place assigns the group to the least loaded machine with enough free
+// capacity and launches it
+func (b *simulatedBackend) place(g *group) bool {
+	var target *machine
+	for _, m := range b.machines {
+		if b.config.Capacity > 0 && m.running+len(g.units) > b.config.Capacity {
+			continue
+		}
+		if target == nil || m.running < target.running {
+			target = m
+		}
+	}
+	if target == nil {
+		return false
+	}
+
+	target.running += len(g.units)
+	for _, u := range g.units {
+		u.machine = target
+		u.currentState = "loaded"
+		u.slot = true
+	}
+	go b.launch(g)
+	return true
+}
+
+func (b *simulatedBackend) launch(g *group) {
+	for _, u := range g.units {
+		b.clock.Sleep(b.lockedSample(b.config.StartLatency))
+
+		b.mu.Lock()
+		if u.desiredState != "launched" {
+			b.mu.Unlock()
+			return
+		}
+		u.currentState = "launched"
+		u.active = true
+		b.mu.Unlock()
+	}
+
+	if g.id != "" {
+		m := g.units[0].machine
+		b.engine.MarkUnitRunning(g.id, m.hostname, m.id)
+	}
+}
+
+func (b *simulatedBackend) stopUnit(u *simUnit, latency time.Duration) {
+	b.clock.Sleep(latency)
+
+	b.mu.Lock()
+	if !u.active {
+		b.mu.Unlock()
+		return
+	}
+	b.deactivate(u)
+	b.schedulePending()
+	b.mu.Unlock()
+
+	b.notifyStopped(u.group, 0)
+}
+
+// notifyStopped tells the engine that a group stopped after latency, once
+func (b *simulatedBackend) notifyStopped(g *group, latency time.Duration) {
+	b.clock.Sleep(latency)
+
+	b.mu.Lock()
+	notify := !g.stopNotified && g.id != ""
+	g.stopNotified = true
+	hostname, machineID := "", ""
+	if m := g.units[0].machine; m != nil {
+		hostname, machineID = m.hostname, m.id
+	}
+	b.mu.Unlock()
+
+	if notify {
+		b.engine.MarkUnitStopped(g.id, hostname, machineID)
+	}
+}
+
+// deactivate marks the unit as not running anymore and releases its slot on
+// the machine
+func (b *simulatedBackend) deactivate(u *simUnit) {
+	if u.slot {
+		u.machine.running--
+		u.slot = false
+	}
+	if u.currentState == "launched" {
+		u.currentState = "loaded"
+	}
+	u.active = false
+}
+
+func (b *simulatedBackend) schedulePending() {
+	pending := b.pending
+	b.pending = nil
+	for _, g := range pending {
+		if !b.place(g) {
+			b.pending = append(b.pending, g)
+		}
+	}
+}
+
+func (b *simulatedBackend) removePending(g *group) {
+	for i, pending := range b.pending {
+		if pending == g {
+			b.pending = append(b.pending[:i], b.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// boundTo returns the unit together with all the units bound to it, directly
+// or transitively, through BindTo options
+func (b *simulatedBackend) boundTo(root *simUnit) []*simUnit {
+	bound := []*simUnit{root}
+	for i := 0; i < len(bound); i++ {
+		for _, u := range bound[i].group.units {
+			for _, option := range u.unit.Options {
+				if option.Section == "Unit" && option.Name == "BindTo" && option.Value == bound[i].unit.Name {
+					bound = append(bound, u)
+				}
+			}
+		}
+	}
+	return bound
+}
+
+func (b *simulatedBackend) sample(d Distribution) time.Duration {
+	if d == nil {
+		return 0
+	}
+	return d.Sample(b.rand)
+}
+
+func (b *simulatedBackend) lockedSample(d Distribution) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sample(d)
+}
+
+func isGlobal(unit schema.Unit) bool {
+	for _, option := range unit.Options {
+		if option.Section == "X-Fleet" && option.Name == "Global" && option.Value == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// instanceID returns the instance part of a templated unit name (%i)
+func instanceID(unitName string) string {
+	name := strings.TrimSuffix(unitName, ".service")
+	if at := strings.Index(name, "@"); at >= 0 {
+		return name[at+1:]
+	}
+	return ""
+}
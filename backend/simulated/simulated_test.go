@@ -0,0 +1,80 @@
+package simulated
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/nomi/definition"
+	"github.com/giantswarm/nomi/unit"
+)
+
+func TestParseDistribution(t *testing.T) {
+	for _, spec := range []string{"const:1", "uniform:0.5,2", "normal:2,0.5", "exp:0.3"} {
+		d, err := ParseDistribution(spec)
+		if err != nil {
+			log.Fatalf("unable to parse distribution %s: %v", spec, err)
+		}
+		if d.String() != spec {
+			log.Fatalf("wrong distribution expected %s got: %s", spec, d.String())
+		}
+	}
+
+	for _, spec := range []string{"const", "gauss:1", "uniform:2,1", "normal:1", "exp:-1"} {
+		if _, err := ParseDistribution(spec); err == nil {
+			log.Fatalf("expected an error parsing distribution %s", spec)
+		}
+	}
+}
+
+func TestSimulatedBenchmark(t *testing.T) {
+	def, err := definition.BenchmarkDefByRawInstructions("(start 20 100) (sleep 30) (stop-all)", 2)
+	if err != nil {
+		log.Fatal(err)
+	}
+	engine, err := unit.NewEngine(def, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	builder, err := unit.NewBuilder(def.Application, engine.InstanceGroupSize(), "127.0.0.1:40302")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	clock := unit.NewScaledClock(1000)
+	engine.UseClock(clock)
+	b := NewSimulatedBackend(Config{
+		Agents:          2,
+		Capacity:        10,
+		ScheduleLatency: constant{0.5},
+		StartLatency:    uniform{1, 2},
+		StopLatency:     constant{1},
+		Seed:            1,
+	}, engine, clock)
+
+	engine.SpawnFunc = func(id string) error {
+		return b.StartUnitGroup(builder.MakeUnitChain(id))
+	}
+	engine.StopFunc = func(id string) error {
+		return b.Stop(builder.GetUnitPrefix() + "-0@" + id + ".service")
+	}
+
+	// only 10 groups fit into the cluster, the rest stays pending
+	engine.Run()
+	// wait for the last stop callbacks
+	clock.Sleep(5 * time.Second)
+
+	stats := engine.Stats()
+	if len(stats.Start) != 10 {
+		log.Fatalf("wrong number of started units expected 10 got: %d", len(stats.Start))
+	}
+	// the pending groups report their stop too
+	if len(stats.Stop) != 20 || stats.StopPending != 0 {
+		log.Fatalf("wrong number of stopped units expected 20 got: %d, %d pending", len(stats.Stop), stats.StopPending)
+	}
+	for _, line := range stats.Start {
+		if line.Delay < 2.5 {
+			log.Fatalf("start delay below the simulated latencies: %f", line.Delay)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/nomi/agent"
+	"github.com/giantswarm/nomi/definition"
+	"github.com/giantswarm/nomi/log"
+)
+
+type agentCmdFlags struct {
+	addr            string
+	monitor         string
+	monitorInterval int
+	hostname        string
+	procRoot        string
+	token           string
+	pinnedPubKey    string
+}
+
+var (
+	agentCmd = &cobra.Command{
+		Use:   "agent",
+		Short: "Collect the metrics of a host for the observer",
+		Long:  "Collect the resource usage of the host and of the monitored processes from /proc and push it to the nomi observer. Deployed by nomi run on every machine.",
+		Run:   agentRun,
+	}
+
+	agentFlags = agentCmdFlags{}
+)
+
+func init() {
+	agentCmd.Flags().StringVar(&agentFlags.addr, "addr", "", "address of the nomi observer")
+	agentCmd.Flags().StringVar(&agentFlags.monitor, "monitor", "", "comma separated list of processes to monitor: command names, PIDs or name=PID pairs")
+	agentCmd.Flags().IntVar(&agentFlags.monitorInterval, "monitor-interval", definition.DefaultMonitorInterval, "sampling interval in seconds")
+	agentCmd.Flags().StringVar(&agentFlags.hostname, "hostname", "", "hostname reported to the observer, the one of the machine by default")
+	agentCmd.Flags().StringVar(&agentFlags.procRoot, "proc", "/proc", "mount point of procfs")
+	agentCmd.Flags().StringVar(&agentFlags.token, "token", "", "callback token of the run")
+	agentCmd.Flags().StringVar(&agentFlags.pinnedPubKey, "pinned-pubkey", "", "reach the observer over https, trusting only this public key (sha256//<base64>)")
+}
+
+func agentRun(cmd *cobra.Command, args []string) {
+	if agentFlags.addr == "" {
+		log.Logger().Fatal("--addr is required")
+	}
+
+	processes, err := definition.ParseMonitorProcesses(agentFlags.monitor)
+	if err != nil {
+		log.Logger().Fatal(err)
+	}
+
+	hostname := agentFlags.hostname
+	if hostname == "" {
+		if hostname, err = os.Hostname(); err != nil {
+			log.Logger().Fatal(err)
+		}
+	}
+
+	agent.New(agent.Config{
+		ObserverAddr: agentFlags.addr,
+		Hostname:     hostname,
+		Monitor: definition.Monitor{
+			Interval:  agentFlags.monitorInterval,
+			Processes: processes,
+		},
+		ProcRoot:     agentFlags.procRoot,
+		Token:        agentFlags.token,
+		PinnedPubKey: agentFlags.pinnedPubKey,
+	}).Run()
+}
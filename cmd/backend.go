@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/giantswarm/nomi/backend"
+	"github.com/giantswarm/nomi/backend/local"
+	"github.com/giantswarm/nomi/backend/simulated"
+	"github.com/giantswarm/nomi/fleet"
+	"github.com/giantswarm/nomi/unit"
+)
+
+const (
+	fleetBackend     = "fleet"
+	localBackend     = "local"
+	simulatedBackend = "simulated"
+
+	defaultBackend = fleetBackend
+)
+
+// newBackend returns the scheduler backend selected by the run flags
+func newBackend(flags runCmdFlags, unitEngine *unit.UnitEngine) (backend.Backend, error) {
+	switch flags.backend {
+	case fleetBackend:
+		config := flags.fleetConfig()
+		if unitEngine != nil {
+			config.Recorder = unitEngine
+		}
+		return fleet.NewFleetPool(flags.fleetConnections, config)
+	case localBackend:
+		return local.NewLocalBackend(), nil
+	case simulatedBackend:
+		config, err := flags.simulationConfig()
+		if err != nil {
+			return nil, err
+		}
+		// The simulated units report to the engine on the same virtual clock
+		clock := unit.NewScaledClock(flags.simSpeedup)
+		unitEngine.UseClock(clock)
+		return simulated.NewSimulatedBackend(config, unitEngine, clock), nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q", flags.backend)
+	}
+}
+
+func (f runCmdFlags) fleetConfig() fleet.Config {
+	return fleet.Config{
+		Endpoint:              f.fleetEndpoint,
+		CAFile:                f.fleetCAFile,
+		CertFile:              f.fleetCertFile,
+		KeyFile:               f.fleetKeyFile,
+		RequestTimeout:        f.requestTimeout,
+		QPS:                   f.fleetQPS,
+		Tunnel:                f.tunnel,
+		KnownHostsFile:        f.knownHostsFile,
+		StrictHostKeyChecking: f.strictHostKeyChecking,
+		Retries:               f.fleetRetries,
+		RetryBackoff:          f.fleetRetryBackoff,
+	}
+}
+
+func (f runCmdFlags) simulationConfig() (simulated.Config, error) {
+	config := simulated.Config{
+		Agents:   f.simAgents,
+		Capacity: f.simCapacity,
+		Seed:     f.simSeed,
+	}
+
+	var err error
+	if config.ScheduleLatency, err = simulated.ParseDistribution(f.simScheduleLatency); err != nil {
+		return config, err
+	}
+	if config.StartLatency, err = simulated.ParseDistribution(f.simStartLatency); err != nil {
+		return config, err
+	}
+	if config.StopLatency, err = simulated.ParseDistribution(f.simStopLatency); err != nil {
+		return config, err
+	}
+	return config, nil
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/coreos/fleet/schema"
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/nomi/log"
+	"github.com/giantswarm/nomi/unit"
+)
+
+type cleanupCmdFlags struct {
+	runCmdFlags
+
+	run     string
+	prefix  string
+	allNomi bool
+	yes     bool
+}
+
+var (
+	cleanupCmd = &cobra.Command{
+		Use:   "cleanup",
+		Short: "Destroy the units left by benchmarks",
+		Long:  "Destroy the units of a run, the units with a prefix or all the units generated by nomi, after showing them and asking for confirmation",
+		Run:   cleanupRun,
+	}
+
+	cleanupFlags = cleanupCmdFlags{}
+)
+
+func init() {
+	cleanupCmd.Flags().StringVar(&cleanupFlags.run, "run", "", "destroy the units of the run with this ID")
+	cleanupCmd.Flags().StringVar(&cleanupFlags.prefix, "prefix", "", "destroy the units whose name starts with this prefix")
+	cleanupCmd.Flags().BoolVar(&cleanupFlags.allNomi, "all-nomi", false, "destroy all the units generated by nomi")
+	cleanupCmd.Flags().BoolVar(&cleanupFlags.yes, "yes", false, "do not ask for confirmation")
+	cleanupCmd.Flags().BoolVar(&cleanupFlags.verbose, "verbose", false, "verbose output")
+	addBackendFlags(cleanupCmd.Flags(), &cleanupFlags.runCmdFlags)
+}
+
+func (f cleanupCmdFlags) Validate() {
+	selectors := 0
+	for _, set := range []bool{f.run != "", f.prefix != "", f.allNomi} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		log.Logger().Fatal("one of --run, --prefix or --all-nomi is required")
+	}
+
+	if f.backend != fleetBackend {
+		log.Logger().Fatal("only the fleet backend keeps units across runs")
+	}
+}
+
+func cleanupRun(cmd *cobra.Command, args []string) {
+	cleanupFlags.Validate()
+
+	scheduler, err := newBackend(cleanupFlags.runCmdFlags, nil)
+	if err != nil {
+		log.Logger().Fatal(err)
+	}
+
+	units, err := scheduler.ListUnits()
+	if err != nil {
+		log.Logger().Fatal(err)
+	}
+
+	prefixes := cleanupFlags.selectPrefixes(units)
+	if len(prefixes) == 0 {
+		fmt.Println("No units to destroy")
+		return
+	}
+
+	names := []string{}
+	for _, prefixNames := range prefixes {
+		names = append(names, prefixNames...)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("The following %d units will be destroyed:\n", len(names))
+	for _, name := range names {
+		fmt.Println("  " + name)
+	}
+	if !cleanupFlags.yes && !confirm("Destroy them?") {
+		fmt.Println("Aborted")
+		return
+	}
+
+	for prefix := range prefixes {
+		if cleanupFlags.verbose {
+			log.Logger().Infof("destroying units with prefix %s", prefix)
+		}
+		if err := scheduler.CleanupPrefix(prefix); err != nil {
+			log.Logger().Fatal(err)
+		}
+	}
+}
+
+// selectPrefixes returns the prefixes to clean up together with the names of
+// the units they match. Runs are told apart by the marker nomi adds to its
+// units, so units generated before runs had an ID are only matched by
+// --prefix.
+func (f cleanupCmdFlags) selectPrefixes(units []*schema.Unit) map[string][]string {
+	prefixes := map[string][]string{}
+	for _, u := range units {
+		if f.prefix != "" {
+			if strings.HasPrefix(u.Name, f.prefix) {
+				prefixes[f.prefix] = append(prefixes[f.prefix], u.Name)
+			}
+			continue
+		}
+
+		runPrefix, ok := unit.RunPrefixOf(u)
+		if !ok || !strings.HasPrefix(u.Name, runPrefix+"-") {
+			continue
+		}
+		if f.allNomi || strings.HasSuffix(runPrefix, "-"+f.run) {
+			prefixes[runPrefix+"-"] = append(prefixes[runPrefix+"-"], u.Name)
+		}
+	}
+	return prefixes
+}
+
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
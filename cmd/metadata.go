@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/nomi/backend"
+	"github.com/giantswarm/nomi/log"
+	"github.com/giantswarm/nomi/unit"
+)
+
+const etcdVersionTimeout = 5 * time.Second
+
+// clusterSnapshot describes the cluster behind the backend, including the
+// version of etcd when its endpoint is known
+func clusterSnapshot(unitEngine *unit.UnitEngine, scheduler backend.Backend, etcdEndpoint string) *unit.ClusterSnapshot {
+	snapshot := unitEngine.TakeClusterSnapshot(scheduler)
+	if etcdEndpoint != "" {
+		dial := net.Dial
+		if dialer, ok := scheduler.(backend.Dialer); ok {
+			dial = dialer.Dial
+		}
+		snapshot.EtcdVersion = etcdVersion(etcdEndpoint, dial)
+	}
+	return snapshot
+}
+
+// etcdVersion returns the answer of the version endpoint of etcd as is, since
+// its format changed across etcd releases. etcd is reached with dial, e.g.
+// through the tunnel of the backend.
+func etcdVersion(endpoint string, dial func(network, addr string) (net.Conn, error)) string {
+	client := &http.Client{Transport: &http.Transport{Dial: dial}, Timeout: etcdVersionTimeout}
+	resp, err := client.Get(strings.TrimSuffix(endpoint, "/") + "/version")
+	if err != nil {
+		log.Logger().Warningf("unable to get the etcd version: %v", err)
+		return ""
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Logger().Warningf("unable to get the etcd version: %v", err)
+		return ""
+	}
+	return strings.TrimSpace(string(body))
+}
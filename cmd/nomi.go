@@ -16,6 +16,10 @@ var (
 func init() {
 	NomiCmd.AddCommand(versionCmd)
 	NomiCmd.AddCommand(runCmd)
+	NomiCmd.AddCommand(preflightCmd)
+	NomiCmd.AddCommand(cleanupCmd)
+	NomiCmd.AddCommand(agentCmd)
+	NomiCmd.AddCommand(notifyCmd)
 }
 
 func nomiRun(cmd *cobra.Command, args []string) {
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/nomi/agent"
+	"github.com/giantswarm/nomi/log"
+)
+
+type notifyCmdFlags struct {
+	addr         string
+	hostname     string
+	machineID    string
+	token        string
+	pinnedPubKey string
+}
+
+var (
+	notifyCmd = &cobra.Command{
+		Use:   "notify (hello|bye) <instance id>",
+		Short: "Report the state change of a benchmark unit to the observer",
+		Long:  "Report that an instance group started (hello) or stopped (bye) to the nomi observer. Run by the benchmark units of the applications using the nomi notifier, on machines without curl.",
+		Run:   notifyRun,
+	}
+
+	notifyFlags = notifyCmdFlags{}
+)
+
+func init() {
+	notifyCmd.Flags().StringVar(&notifyFlags.addr, "addr", "", "address of the nomi observer")
+	notifyCmd.Flags().StringVar(&notifyFlags.hostname, "host", "", "hostname of the machine running the unit")
+	notifyCmd.Flags().StringVar(&notifyFlags.machineID, "machine", "", "ID of the machine running the unit")
+	notifyCmd.Flags().StringVar(&notifyFlags.token, "token", "", "callback token of the run")
+	notifyCmd.Flags().StringVar(&notifyFlags.pinnedPubKey, "pinned-pubkey", "", "reach the observer over https, trusting only this public key (sha256//<base64>)")
+}
+
+func notifyRun(cmd *cobra.Command, args []string) {
+	if len(args) != 2 || (args[0] != "hello" && args[0] != "bye") {
+		log.Logger().Fatal("usage: nomi notify (hello|bye) <instance id>")
+	}
+	if notifyFlags.addr == "" {
+		log.Logger().Fatal("--addr is required")
+	}
+
+	err := agent.Notify(agent.Config{
+		ObserverAddr: notifyFlags.addr,
+		Hostname:     notifyFlags.hostname,
+		Token:        notifyFlags.token,
+		PinnedPubKey: notifyFlags.pinnedPubKey,
+	}, args[0], args[1], notifyFlags.machineID)
+	if err != nil {
+		log.Logger().Fatalf("unable to notify %s of %s: %v", args[0], args[1], err)
+	}
+}
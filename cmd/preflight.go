@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/nomi/backend"
+	"github.com/giantswarm/nomi/definition"
+	"github.com/giantswarm/nomi/log"
+	"github.com/giantswarm/nomi/preflight"
+	"github.com/giantswarm/nomi/unit"
+)
+
+var (
+	preflightCmd = &cobra.Command{
+		Use:   "preflight",
+		Short: "Check that a benchmark can run",
+		Long:  "Check the backend, leftover units, gnuplot, the callback address and the container runtimes of the cluster without running the benchmark",
+		Run:   preflightRun,
+	}
+
+	preflightFlags = runCmdFlags{}
+)
+
+func init() {
+	addBenchmarkFlags(preflightCmd.Flags(), &preflightFlags)
+	addBackendFlags(preflightCmd.Flags(), &preflightFlags)
+}
+
+func preflightRun(cmd *cobra.Command, args []string) {
+	preflightFlags.Validate()
+	preflightFlags.resolveListenAddr()
+	preflightFlags.resolveRunID()
+	preflightFlags.resolveTLS()
+
+	benchmark, unitEngine, builder := loadBenchmark(preflightFlags)
+
+	scheduler, err := newBackend(preflightFlags, unitEngine)
+	if err != nil {
+		log.Logger().Fatal(err)
+	}
+
+	report := preflightReport(preflightFlags, scheduler, builder, benchmark)
+	report.Print(os.Stdout)
+	if !report.Go() {
+		os.Exit(1)
+	}
+}
+
+// preflightReport runs the pre-flight checks of a benchmark. The simulated
+// backend does not run the probe unit.
+func preflightReport(flags runCmdFlags, scheduler backend.Backend, builder *unit.Builder, benchmark definition.BenchmarkDef) preflight.Report {
+	return preflight.Run(preflight.Config{
+		Backend:      scheduler,
+		Builder:      builder,
+		ListenAddr:   flags.listenAddr,
+		AppType:      benchmark.Application.Type,
+		Plots:        flags.generatePlots,
+		AgentBinary:  flags.agentBinaryToCheck(),
+		Probe:        flags.backend != simulatedBackend,
+		ProbeTimeout: flags.preflightTimeout,
+		Certificate:  flags.certificate,
+	})
+}
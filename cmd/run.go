@@ -1,35 +1,104 @@
 package cmd
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"net"
 	"os"
 	"os/exec"
+	"regexp"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/coreos/fleet/schema"
+	"github.com/coreos/fleet/ssh"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
+	"github.com/giantswarm/nomi/backend"
 	"github.com/giantswarm/nomi/definition"
 	"github.com/giantswarm/nomi/fleet"
 	"github.com/giantswarm/nomi/log"
 	"github.com/giantswarm/nomi/output"
+	"github.com/giantswarm/nomi/preflight"
+	"github.com/giantswarm/nomi/tlspin"
 	"github.com/giantswarm/nomi/unit"
 )
 
 const (
 	listenerDefaultIP   = "127.0.0.1"
 	listenerDefaultPort = "40302"
+
+	// observerShutdownTimeout is how long the callbacks in progress have to
+	// complete once the units are destroyed. A connection which never sent a
+	// request only counts as idle after 5s, the timeout leaves the callbacks
+	// their time on top of it.
+	observerShutdownTimeout = 20 * time.Second
+
+	// stopTimeout is how long the units have to report they stopped at the
+	// end of the benchmark
+	stopTimeout = 30 * time.Second
+
+	// dryRunSampleID is the instance id of the units printed in a dry-run
+	dryRunSampleID = "0123456789"
 )
 
+var runIDRegexp = regexp.MustCompile("^[a-zA-Z0-9]+$")
+
 type runCmdFlags struct {
 	listenAddr      string
 	benchmarkFile   string
 	rawInstructions string
 	dumpJSONFlag    bool
+	outputs         []string
 	dumpHTMLTarFlag bool
 	generatePlots   bool
 	igSize          int
 	verbose         bool
 	unitFile        string
+	backend         string
+	runID           string
+	controlToken    string
+	callbackToken   string
+	notifier        string
+	tls             bool
+	monitor         string
+	monitorInterval int
+	agentBinary     string
+	dryRun          bool
+	skipPreflight   bool
+
+	preflightTimeout time.Duration
+
+	fleetEndpoint         string
+	fleetCAFile           string
+	fleetCertFile         string
+	fleetKeyFile          string
+	requestTimeout        time.Duration
+	fleetConnections      int
+	fleetQPS              float64
+	fleetRetries          int
+	fleetRetryBackoff     time.Duration
+	phaseInterval         time.Duration
+	etcdEndpoint          string
+	tunnel                string
+	knownHostsFile        string
+	strictHostKeyChecking bool
+
+	simAgents          int
+	simCapacity        int
+	simScheduleLatency string
+	simStartLatency    string
+	simStopLatency     string
+	simSpeedup         float64
+	simSeed            int64
+
+	// certificate served when --tls is set, and the pin of its public key
+	certificate  *tls.Certificate
+	pinnedPubKey string
 }
 
 func (f runCmdFlags) Validate() {
@@ -37,13 +106,6 @@ func (f runCmdFlags) Validate() {
 		log.Logger().Fatal("dump option is required. Please, choose between:  dump-json OR dump-html-tar")
 	}
 
-	if f.generatePlots {
-		if _, err := exec.LookPath("gnuplot"); err != nil {
-			log.Logger().Infof("generate-gnuplots: could not find path to 'gnuplot':\n%v\n", err)
-			log.Logger().Fatal("generate-gnuplots option requires 'gnuplot' software installed")
-		}
-	}
-
 	if f.benchmarkFile == "" && f.rawInstructions == "" {
 		log.Logger().Fatal("benchmark file definition or raw instructions is required")
 	}
@@ -56,73 +118,174 @@ func (f runCmdFlags) Validate() {
 		log.Logger().Fatal("instance group size has to be greater than 0 when using raw-instructions parameter")
 	}
 
-}
+	if f.runID != "" && !runIDRegexp.MatchString(f.runID) {
+		log.Logger().Fatal("run id can only contain letters and digits")
+	}
 
-var (
-	runCmd = &cobra.Command{
-		Use:   "run",
-		Short: "Run benchmark",
-		Long:  "Run a fleet benchmark based on the provided configuration",
-		Run:   runRun,
+	if f.callbackToken != "" && !runIDRegexp.MatchString(f.callbackToken) {
+		log.Logger().Fatal("callback token can only contain letters and digits")
 	}
 
-	runFlags = runCmdFlags{}
-)
+	for _, o := range f.outputs {
+		format, dir := parseOutput(o)
+		if (format != output.FormatCSV && format != output.FormatTSV) || dir == "" {
+			log.Logger().Fatalf("invalid output %q, expected csv=<dir> or tsv=<dir>", o)
+		}
+	}
 
-func init() {
-	runCmd.Flags().StringVar(&runFlags.listenAddr, "addr", "", "address to listen")
-	runCmd.Flags().StringVar(&runFlags.benchmarkFile, "benchmark-file", "", "file with the benchmark definition (application definition, instance group size, instructions to spawn/stop/float units)")
-	runCmd.Flags().StringVar(&runFlags.rawInstructions, "raw-instructions", "", "instructions to spawn/stop/float units")
-	runCmd.Flags().BoolVar(&runFlags.dumpJSONFlag, "dump-json", false, "dump json stats to stdout")
-	runCmd.Flags().BoolVar(&runFlags.dumpHTMLTarFlag, "dump-html-tar", false, "dump tarred html stats to stdout")
-	runCmd.Flags().BoolVar(&runFlags.verbose, "verbose", false, "verbose output")
-	runCmd.Flags().BoolVar(&runFlags.generatePlots, "generate-gnuplots", false, "generate plots using gnuplot (output directory=/nomi_plots)")
-	runCmd.Flags().IntVar(&runFlags.igSize, "instancegroup-size", 1, "instance group size")
 }
 
-func runRun(cmd *cobra.Command, args []string) {
-	runFlags.Validate()
-	fleetPool := fleet.NewFleetPool(20)
+// checkGnuplot stops nomi when plots are requested without gnuplot installed
+func (f runCmdFlags) checkGnuplot() {
+	if f.generatePlots {
+		if _, err := exec.LookPath("gnuplot"); err != nil {
+			log.Logger().Infof("generate-gnuplots: could not find path to 'gnuplot':\n%v\n", err)
+			log.Logger().Fatal("generate-gnuplots option requires 'gnuplot' software installed")
+		}
+	}
+}
 
-	if runFlags.listenAddr == "" {
-		// We extract the public CoreOS ip of the host machine
+// resolveListenAddr defaults the address to listen on to the public CoreOS ip
+// of the host machine
+func (f *runCmdFlags) resolveListenAddr() {
+	if f.listenAddr == "" {
 		ip, err := fleet.CoreosHostPublicIP()
 		if ip == "" || err != nil {
-			runFlags.listenAddr = listenerDefaultIP + ":" + listenerDefaultPort
+			f.listenAddr = listenerDefaultIP + ":" + listenerDefaultPort
 		} else {
-			runFlags.listenAddr = ip + ":" + listenerDefaultPort
+			f.listenAddr = ip + ":" + listenerDefaultPort
 		}
 	}
+}
 
-	existingUnits, err := fleetPool.ListUnits()
+// resolveRunID generates a random run id unless one was given
+func (f *runCmdFlags) resolveRunID() {
+	if f.runID == "" {
+		b := make([]byte, 4)
+		if _, err := rand.Read(b); err != nil {
+			log.Logger().Fatal(err)
+		}
+		f.runID = hex.EncodeToString(b)
+	}
+}
+
+// resolveTLS generates the self-signed certificate of the observer when the
+// units have to reach it over TLS
+func (f *runCmdFlags) resolveTLS() {
+	if !f.tls || f.certificate != nil {
+		return
+	}
+	host, _, err := net.SplitHostPort(f.listenAddr)
 	if err != nil {
-		log.Logger().Fatal(err)
+		log.Logger().Fatalf("wrong address %s: %v", f.listenAddr, err)
+	}
+	certificate, pin, err := tlspin.NewSelfSigned(host)
+	if err != nil {
+		log.Logger().Fatalf("unable to generate the certificate of the observer: %v", err)
+	}
+	f.certificate, f.pinnedPubKey = &certificate, pin
+}
+
+// resolveControlToken generates a random token for the control API unless one
+// was given
+func (f *runCmdFlags) resolveControlToken() {
+	if f.controlToken == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			log.Logger().Fatal(err)
+		}
+		f.controlToken = hex.EncodeToString(b)
+	}
+}
+
+// resolveNotifier overrides the notifier of the application with --notifier
+// and checks that it can work with the other flags
+func (f runCmdFlags) resolveNotifier(notifier string) string {
+	if f.notifier != "" {
+		notifier = f.notifier
+	}
+	switch notifier {
+	case "", definition.NotifierCurl, definition.NotifierNomi, definition.NotifierState:
+	case definition.NotifierBash:
+		if f.tls {
+			log.Logger().Fatal("the bash notifier cannot reach nomi over TLS, use the curl or nomi notifier with --tls")
+		}
+	default:
+		log.Logger().Fatalf("wrong notifier %s, expected curl, bash, nomi or state", notifier)
 	}
+	return notifier
+}
 
-	var benchmark definition.BenchmarkDef
-	if runFlags.benchmarkFile == "" {
-		benchmark, err = definition.BenchmarkDefByRawInstructions(runFlags.rawInstructions, runFlags.igSize)
+// resolveMonitor overrides the monitor section of the definition with the
+// flags, the defaults are filled by statsDumpers
+func (f runCmdFlags) resolveMonitor(monitor definition.Monitor) (definition.Monitor, error) {
+	if f.monitor != "" {
+		processes, err := definition.ParseMonitorProcesses(f.monitor)
 		if err != nil {
-			log.Logger().Fatal("unable to parse the introduced raw instructions")
+			return monitor, err
+		}
+		monitor.Processes = processes
+	}
+	if f.monitorInterval > 0 {
+		monitor.Interval = f.monitorInterval
+	}
+	return monitor, nil
+}
+
+// agentBinaryToCheck returns the nomi binary served to the agents when they
+// run on the machines of a cluster, which may not be the platform of nomi
+func (f runCmdFlags) agentBinaryToCheck() string {
+	if f.backend != fleetBackend {
+		return ""
+	}
+	if f.agentBinary != "" {
+		return f.agentBinary
+	}
+	return unit.ExecutablePath()
+}
+
+// loadBenchmark parses the benchmark definition and prepares the engine and
+// the builder of its units
+func loadBenchmark(f runCmdFlags) (definition.BenchmarkDef, *unit.UnitEngine, *unit.Builder) {
+	var (
+		benchmark definition.BenchmarkDef
+		err       error
+	)
+	if f.benchmarkFile == "" {
+		benchmark, err = definition.BenchmarkDefByRawInstructions(f.rawInstructions, f.igSize)
+		if err != nil {
+			log.Logger().Fatalf("unable to parse the introduced raw instructions: %v", err)
 		}
 	} else {
-		benchmark, err = definition.BenchmarkDefByFile(runFlags.benchmarkFile)
+		benchmark, err = definition.BenchmarkDefByFile(f.benchmarkFile)
+	}
+
+	if err != nil {
+		log.Logger().Fatal(err)
 	}
 
+	benchmark.Monitor, err = f.resolveMonitor(benchmark.Monitor)
 	if err != nil {
 		log.Logger().Fatal(err)
 	}
+	benchmark.Application.Notifier = f.resolveNotifier(benchmark.Application.Notifier)
 
-	unitEngine, err := unit.NewEngine(benchmark, runFlags.verbose)
+	unitEngine, err := unit.NewEngine(benchmark, f.verbose)
 	if err != nil {
 		log.Logger().Fatal(err)
 	}
 
-	builder, err := unit.NewBuilder(benchmark.Application, unitEngine.InstanceGroupSize(), runFlags.listenAddr)
+	builder, err := unit.NewBuilder(benchmark.Application, unitEngine.InstanceGroupSize(), f.listenAddr)
 	if err != nil {
 		log.Logger().Fatal(err)
 	}
 
+	builder.UseRunID(f.runID)
+	builder.UseCallbackToken(f.callbackToken)
+	if f.certificate != nil {
+		builder.UseTLS(f.pinnedPubKey)
+	}
+
 	if benchmark.Application.Type == "unitfiles" {
 		err = builder.UseCustomUnitFileService(benchmark.Application.UnitFilePath)
 		if err != nil {
@@ -130,52 +293,234 @@ func runRun(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	observer := unit.NewUnitObserver(unitEngine)
-	observer.StartHTTPService(runFlags.listenAddr)
+	return benchmark, unitEngine, builder
+}
+
+var (
+	runCmd = &cobra.Command{
+		Use:   "run",
+		Short: "Run benchmark",
+		Long:  "Run a fleet benchmark based on the provided configuration",
+		Run:   runRun,
+	}
 
-	fleetPool.StartUnit(builder.MakeStatsDumper("etcd", "echo `hostname` `docker run --rm --pid=host ragnarb/toolbox pidstat -h -r -u -C etcd 10 1 | tail -n 1 | awk \\'{print $7 \" \" $12}\\'`", "etcd"))
-	fleetPool.StartUnit(builder.MakeStatsDumper("fleetd", "echo `hostname` `docker run --rm --pid=host ragnarb/toolbox pidstat -h -r -u -C fleetd 10 1 | tail -n 1 | awk \\'{print $7 \" \" $12}\\'`", "fleetd"))
-	fleetPool.StartUnit(builder.MakeStatsDumper("systemd", "echo `hostname` `docker run --rm --pid=host ragnarb/toolbox pidstat -h -r -u -p 1 10 1 | tail -n 1 | awk \\'{print $7 \" \" $12}\\'`", "systemd"))
+	runFlags = runCmdFlags{}
+)
+
+func init() {
+	addBenchmarkFlags(runCmd.Flags(), &runFlags)
+	runCmd.Flags().BoolVar(&runFlags.dumpJSONFlag, "dump-json", false, "dump json stats to stdout")
+	runCmd.Flags().BoolVar(&runFlags.dumpHTMLTarFlag, "dump-html-tar", false, "dump tarred html stats to stdout")
+	runCmd.Flags().StringSliceVar(&runFlags.outputs, "output", nil, "write every collected series to a file per series in a directory, as format=dir with format csv or tsv, e.g. csv=./results")
+	runCmd.Flags().BoolVar(&runFlags.dryRun, "dry-run", false, "print the generated units and the timeline of the instructions without running the benchmark")
+	runCmd.Flags().BoolVar(&runFlags.skipPreflight, "skip-preflight", false, "start the benchmark without running the pre-flight checks")
+	runCmd.Flags().DurationVar(&runFlags.phaseInterval, "phase-interval", time.Second, "interval between polls of the unit states to measure the phases of the start operation (0 disables it)")
+	runCmd.Flags().StringVar(&runFlags.etcdEndpoint, "etcd-endpoint", "", "etcd endpoint to record its version in the results, e.g. http://127.0.0.1:2379")
+	runCmd.Flags().StringVar(&runFlags.controlToken, "control-token", "", "token authenticating the requests to the control API (random by default)")
+	addBackendFlags(runCmd.Flags(), &runFlags)
+}
+
+// addBenchmarkFlags defines the flags describing the benchmark and where nomi
+// listens for the units
+func addBenchmarkFlags(flags *pflag.FlagSet, f *runCmdFlags) {
+	flags.StringVar(&f.listenAddr, "addr", "", "address to listen")
+	flags.StringVar(&f.benchmarkFile, "benchmark-file", "", "file with the benchmark definition (application definition, instance group size, instructions to spawn/stop/float units)")
+	flags.StringVar(&f.rawInstructions, "raw-instructions", "", "instructions to spawn/stop/float units")
+	flags.BoolVar(&f.verbose, "verbose", false, "verbose output")
+	flags.BoolVar(&f.generatePlots, "generate-gnuplots", false, "generate plots using gnuplot (output directory=/nomi_plots)")
+	flags.IntVar(&f.igSize, "instancegroup-size", 1, "instance group size")
+	flags.StringVar(&f.monitor, "monitor", "", "comma separated processes to monitor on every machine: names, PIDs or name=PID, e.g. fleetd,docker,systemd=1")
+	flags.IntVar(&f.monitorInterval, "monitor-interval", 0, "sampling interval of the monitored processes in seconds (default 10)")
+	flags.StringVar(&f.agentBinary, "agent-binary", "", "nomi binary the agents download, built for the cluster machines (default the running binary)")
+	flags.StringVar(&f.runID, "run-id", "", "ID of the run added to the names of the units (random by default)")
+	flags.StringVar(&f.notifier, "notifier", "", "how the units report their state changes: curl, bash, nomi or state (default curl, or the notifier of the application)")
+	flags.StringVar(&f.callbackToken, "callback-token", "", "token the units add to their requests to nomi, the others are rejected (letters and digits)")
+	flags.BoolVar(&f.tls, "tls", false, "serve https with a generated self-signed certificate whose public key the units pin")
+	flags.DurationVar(&f.preflightTimeout, "preflight-timeout", 60*time.Second, "time to wait for every machine to run the pre-flight probe unit")
+}
+
+// addBackendFlags defines the flags selecting and configuring the scheduler
+// backend
+func addBackendFlags(flags *pflag.FlagSet, f *runCmdFlags) {
+	flags.StringVar(&f.backend, "backend", defaultBackend, "scheduler backend to benchmark (fleet|local|simulated)")
+	flags.StringVar(&f.fleetEndpoint, "endpoint", fleet.DefaultEndpoint, "fleet API endpoint (unix://<socket> or http(s)://<host>:<port>)")
+	flags.StringVar(&f.fleetCAFile, "ca-file", "", "CA certificate file to verify an https fleet endpoint")
+	flags.StringVar(&f.fleetCertFile, "cert-file", "", "client certificate file for an https fleet endpoint")
+	flags.StringVar(&f.fleetKeyFile, "key-file", "", "client key file for an https fleet endpoint")
+	flags.DurationVar(&f.requestTimeout, "request-timeout", 0, "timeout of the fleet API requests (0 means no timeout)")
+	flags.IntVar(&f.fleetConnections, "fleet-connections", 20, "number of connections to the fleet API and of requests in flight at most")
+	flags.Float64Var(&f.fleetQPS, "fleet-qps", 0, "maximum requests per second to the fleet API (0 means unlimited)")
+	flags.IntVar(&f.fleetRetries, "fleet-retries", 5, "maximum retries of a fleet API request failing with a transient error")
+	flags.DurationVar(&f.fleetRetryBackoff, "fleet-retry-backoff", 100*time.Millisecond, "time to wait before the first retry of a fleet API request, doubled on every retry")
+	flags.StringVar(&f.tunnel, "tunnel", "", "reach the fleet endpoint through an SSH tunnel to [user@]host[:port]")
+	flags.StringVar(&f.knownHostsFile, "known-hosts-file", ssh.DefaultKnownHostsPath, "file used to store remote machine fingerprints of the SSH tunnel")
+	flags.BoolVar(&f.strictHostKeyChecking, "strict-host-key-checking", true, "verify the host key of the SSH tunnel")
+	flags.IntVar(&f.simAgents, "sim-agents", 3, "simulated backend: number of machines")
+	flags.IntVar(&f.simCapacity, "sim-capacity", 0, "simulated backend: maximum running units per machine (0 means unlimited)")
+	flags.StringVar(&f.simScheduleLatency, "sim-schedule-latency", "exp:0.5", "simulated backend: distribution of the scheduling latency in seconds")
+	flags.StringVar(&f.simStartLatency, "sim-start-latency", "normal:2,0.5", "simulated backend: distribution of the unit start latency in seconds")
+	flags.StringVar(&f.simStopLatency, "sim-stop-latency", "normal:1,0.2", "simulated backend: distribution of the unit stop latency in seconds")
+	flags.Float64Var(&f.simSpeedup, "sim-speedup", 100, "simulated backend: how many times faster than the wall clock the benchmark runs")
+	flags.Int64Var(&f.simSeed, "sim-seed", 0, "simulated backend: seed of the latency generator (0 means random)")
+}
+
+func runRun(cmd *cobra.Command, args []string) {
+	runFlags.Validate()
+	runFlags.resolveListenAddr()
+	runFlags.resolveRunID()
+	runFlags.resolveTLS()
+
+	benchmark, unitEngine, builder := loadBenchmark(runFlags)
+
+	if runFlags.dryRun {
+		units := append(builder.MakeUnitChain(dryRunSampleID), statsDumpers(builder, benchmark.Monitor)...)
+		output.PrintDryRun(units, unitEngine.Timeline(), os.Stdout)
+		return
+	}
+
+	log.Logger().Infof("run %s, units prefixed with %s", runFlags.runID, builder.GetUnitPrefix())
+
+	scheduler, err := newBackend(runFlags, unitEngine)
+	if err != nil {
+		log.Logger().Fatal(err)
+	}
+
+	if runFlags.skipPreflight {
+		runFlags.checkGnuplot()
+		// the units of this run left over would be mistaken for the new ones,
+		// and an agent binary the machines cannot run leaves the stats empty
+		config := preflight.Config{Backend: scheduler, Builder: builder, AgentBinary: runFlags.agentBinaryToCheck()}
+		for _, result := range []preflight.Result{preflight.CheckLeftovers(config), preflight.CheckAgentBinary(config)} {
+			if result.Status == preflight.Warn || result.Status == preflight.Fail {
+				log.Logger().Warningf("%s: %s", result.Name, result.Detail)
+			}
+		}
+	} else {
+		report := preflightReport(runFlags, scheduler, builder, benchmark)
+		report.Print(os.Stderr)
+		if !report.Go() {
+			log.Logger().Fatal("pre-flight checks failed, fix them or run with --skip-preflight")
+		}
+	}
+
+	metadata := unit.Metadata{
+		RunID:      runFlags.runID,
+		Version:    ProjectVersion,
+		Build:      ProjectBuild,
+		Backend:    runFlags.backend,
+		Definition: benchmark,
+		Before:     clusterSnapshot(unitEngine, scheduler, runFlags.etcdEndpoint),
+	}
+
+	observer := unit.NewUnitObserver(unitEngine)
+	observer.UseDashboard(output.Asset)
+	runFlags.resolveControlToken()
+	observer.UseControl(runFlags.controlToken)
+	observer.UseCallbackToken(runFlags.callbackToken)
+	observer.UseAgentBinary(runFlags.agentBinary)
+	if runFlags.certificate != nil {
+		observer.UseTLS(*runFlags.certificate)
+		log.Logger().Infof("serving https, certificate public key %s", runFlags.pinnedPubKey)
+	}
+	log.Logger().Infof("control API token: %s", runFlags.controlToken)
+	callbackAddr, err := observer.StartHTTPService(runFlags.listenAddr)
+	if err != nil {
+		log.Logger().Fatalf("unable to listen on %s: %v", runFlags.listenAddr, err)
+	}
+	builder.UseListenAddr(callbackAddr)
+	log.Logger().Infof("listening on %s", callbackAddr)
+
+	// the agents are only required when the monitoring was asked for, with
+	// the flags or in the definition
+	monitorRequested := benchmark.Monitor.Interval > 0 || len(benchmark.Monitor.Processes) > 0
+	for _, dumper := range statsDumpers(builder, benchmark.Monitor) {
+		if err := scheduler.StartUnit(dumper); err != nil {
+			if monitorRequested {
+				log.Logger().Fatalf("unable to start the agent unit %s, the monitored processes would not be reported: %v", dumper.Name, err)
+			}
+			log.Logger().Warningf("unable to start the agent unit %s, the host and machine stats are missing: %v", dumper.Name, err)
+		}
+	}
 
 	unitEngine.SpawnFunc = func(id string) error {
 		if runFlags.verbose {
 			log.Logger().Infof("spawning unit with id %s\n", id)
 		}
-		return fleetPool.StartUnitGroup(builder.MakeUnitChain(id))
+		return scheduler.StartUnitGroup(builder.MakeUnitChain(id))
 	}
 
 	unitEngine.StopFunc = func(id string) error {
 		if runFlags.verbose {
 			log.Logger().Infof("stopping unit with id %s\n", id)
 		}
-		return fleetPool.Stop(builder.GetUnitPrefix() + "-0@" + id + ".service")
+		return scheduler.Stop(builder.GetUnitPrefix() + "-0@" + id + ".service")
+	}
+
+	stopWatcher := func() {}
+	polled := scheduler
+	if p, ok := scheduler.(backend.Poller); ok {
+		polled = p.Poller()
+	}
+	if lister, ok := polled.(unit.UnitLister); ok && runFlags.phaseInterval > 0 {
+		stopWatcher = unitEngine.WatchPhases(lister, builder.GetUnitPrefix(), runFlags.phaseInterval)
+	} else if benchmark.Application.Notifier == definition.NotifierState {
+		log.Logger().Fatal("the state notifier needs the unit states of the backend to be polled, set --phase-interval")
 	}
 
 	unitEngine.Run()
+	// the units are destroyed, and the agents delete the nomi binary, only
+	// once the last stops are reported
+	if !unitEngine.WaitStopped(stopTimeout) {
+		log.Logger().Warning("some units did not report they stopped, their stop delays are missing")
+	}
+	stopWatcher()
+
+	metadata.After = clusterSnapshot(unitEngine, scheduler, runFlags.etcdEndpoint)
+	unitEngine.SetMetadata(metadata)
 
-	existingUnits, err = fleetPool.ListUnits()
+	existingUnits, err := scheduler.ListUnits()
 	if err != nil {
 		log.Logger().Errorf("error listing units %v", err)
 	}
 
 	wg := new(sync.WaitGroup)
 	for _, unit := range existingUnits {
-		if strings.HasPrefix(unit.Name, builder.GetUnitPrefix()) {
+		if strings.HasPrefix(unit.Name, builder.GetUnitPrefix()+"-") {
 			wg.Add(1)
 			go func(unitName string) {
 				if runFlags.verbose {
 					log.Logger().Infof("destroying old unit: %s", unitName)
 				}
-				fleetPool.Destroy(unitName)
+				scheduler.Destroy(unitName)
 				wg.Done()
 			}(unit.Name)
 		}
 	}
 	wg.Wait()
 
+	ctx, cancel := context.WithTimeout(context.Background(), observerShutdownTimeout)
+	if err := observer.Shutdown(ctx); err != nil {
+		log.Logger().Warningf("some callbacks may be missing from the report: %v", err)
+	}
+	cancel()
+
 	generateBenchmarkReport(runFlags.dumpJSONFlag, runFlags.dumpHTMLTarFlag, runFlags.generatePlots, unitEngine)
 }
 
+// parseOutput splits an --output value into its format and its directory
+func parseOutput(o string) (string, string) {
+	parts := strings.SplitN(o, "=", 2)
+	if len(parts) != 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// statsDumpers creates the agent unit collecting the metrics of every host
+func statsDumpers(builder *unit.Builder, monitor definition.Monitor) []schema.Unit {
+	return []schema.Unit{builder.MakeStatsDumper(monitor.WithDefaults())}
+}
+
 func generateBenchmarkReport(dumpJSONFlag, dumpHTMLTarFlag, generatePlots bool, unitEngine *unit.UnitEngine) {
 	if dumpJSONFlag {
 		output.DumpJSON(unitEngine.Stats())
@@ -192,12 +537,28 @@ func generateBenchmarkReport(dumpJSONFlag, dumpHTMLTarFlag, generatePlots bool,
 			log.Logger().Fatal(err)
 		}
 
-		output.DumpHTMLTar(html, scriptJs, unitEngine.Stats())
+		styleCSS, err := output.Asset("output/embedded/style.css")
+		if err != nil {
+			log.Logger().Fatal(err)
+		}
+
+		output.DumpHTMLTar(html, scriptJs, styleCSS, unitEngine.Stats())
 	}
 
 	if generatePlots {
 		output.GeneratePlots(unitEngine.Stats(), runFlags.verbose)
 	}
 
-	output.PrintReport(unitEngine.Stats(), os.Stderr)
+	for _, o := range runFlags.outputs {
+		format, dir := parseOutput(o)
+		if err := output.ExportTables(unitEngine.Stats(), format, dir); err != nil {
+			log.Logger().Errorf("unable to write the %s files to %s: %v", format, dir, err)
+		}
+	}
+
+	stats := unitEngine.Stats()
+	output.PrintReport(stats, os.Stderr)
+	if failed := stats.FailedAssertions(); failed > 0 {
+		log.Logger().Fatalf("%d of %d assertions failed", failed, len(stats.Assertions))
+	}
 }
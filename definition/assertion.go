@@ -0,0 +1,58 @@
+package definition
+
+import (
+	"regexp"
+
+	"github.com/giantswarm/nomi/log"
+)
+
+// Aggregates of the application metrics an assertion can check
+const (
+	AggregateCount = "count"
+	AggregateSum   = "sum"
+	AggregateMean  = "mean"
+	AggregateMin   = "min"
+	AggregateMax   = "max"
+)
+
+// MetricNameRegexp matches the names of the metrics the benchmark units report
+var MetricNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.:-]+$`)
+
+// Assertion checks an aggregate of a metric reported by the benchmark units
+// over the whole benchmark, e.g. that the mean time to the first request
+// served is lower than 2 seconds
+type Assertion struct {
+	Metric    string              `yaml:"metric"`
+	Aggregate string              `yaml:"aggregate"`
+	Symbol    ExpectRunningSymbol `yaml:"symbol"`
+	Value     float64             `yaml:"value"`
+}
+
+// Check tells whether the actual value of the aggregate satisfies the
+// assertion
+func (a Assertion) Check(actual float64) bool {
+	if a.Symbol == Lower {
+		return actual < a.Value
+	}
+	return actual > a.Value
+}
+
+func validateAssertions(assertions []Assertion) bool {
+	for _, assertion := range assertions {
+		if !MetricNameRegexp.MatchString(assertion.Metric) {
+			log.Logger().Errorf("wrong metric name of assertion %v", assertion)
+			return false
+		}
+		switch assertion.Aggregate {
+		case AggregateCount, AggregateSum, AggregateMean, AggregateMin, AggregateMax:
+		default:
+			log.Logger().Errorf("wrong aggregate of assertion %v, expected one of count, sum, mean, min or max", assertion)
+			return false
+		}
+		if assertion.Symbol != Lower && assertion.Symbol != Greater {
+			log.Logger().Errorf("wrong symbol of assertion %v, expected < or >", assertion)
+			return false
+		}
+	}
+	return true
+}
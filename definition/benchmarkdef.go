@@ -3,6 +3,7 @@
 package definition
 
 import (
+	"fmt"
 	"io/ioutil"
 	"path/filepath"
 	"regexp"
@@ -28,6 +29,18 @@ const (
 
 	Lower   ExpectRunningSymbol = "<"
 	Greater ExpectRunningSymbol = ">"
+
+	// NotifierCurl calls back with curl, the default
+	NotifierCurl = "curl"
+	// NotifierBash calls back with a plain HTTP request written to the
+	// /dev/tcp device of bash
+	NotifierBash = "bash"
+	// NotifierNomi calls back with nomi notify, the binary deployed with the
+	// agent
+	NotifierNomi = "nomi"
+	// NotifierState infers the state changes of the units from the states
+	// reported by the backend, the units don't call back
+	NotifierState = "state"
 )
 
 type Start struct {
@@ -62,6 +75,8 @@ type Application struct {
 	Args         []string
 	Envs         map[string]string
 	UnitFilePath string `yaml:"unitfile-path"`
+	// Notifier tells how the units report their state changes to nomi
+	Notifier string
 }
 
 type Volumes []Volume
@@ -75,6 +90,8 @@ type BenchmarkDef struct {
 	Application       Application
 	Instructions      Instructions
 	InstanceGroupSize int `yaml:"instancegroup-size"`
+	Monitor           Monitor
+	Assertions        []Assertion
 }
 
 // BenchmarkDefByFile procudes a benchmark definition out of a YAML file
@@ -89,7 +106,7 @@ func BenchmarkDefByFile(filePath string) (BenchmarkDef, error) {
 
 // BenchmarkDefByRawInstructions creates a benchmark definition using raw
 // instructions and instance group size
-// Return a benchmark definition and error
+// Return a benchmark definition and an error when an instruction is malformed
 func BenchmarkDefByRawInstructions(instructions string, igSize int) (BenchmarkDef, error) {
 	re := regexp.MustCompile(`\(([^\)]+)\)`)
 	parsed := re.FindAllStringSubmatch(instructions, -1)
@@ -116,17 +133,17 @@ func BenchmarkDefByRawInstructions(instructions string, igSize int) (BenchmarkDe
 		switch cmd {
 		case instructionStart:
 			if len(args) != 2 {
-				log.Logger().Fatalf("start requires 2 arguments: max and time between starts. eg: (start 10 100ms)")
+				return def, fmt.Errorf("start requires 2 arguments: max and time between starts. eg: (start 10 100)")
 			}
 
 			max, err := strconv.Atoi(args[0])
 			if err != nil {
-				log.Logger().Fatalf("%v", err)
+				return def, fmt.Errorf("start: %v", err)
 			}
 			var interval int
 			interval, err = strconv.Atoi(args[1])
 			if err != nil {
-				log.Logger().Fatalf("%v", err)
+				return def, fmt.Errorf("start: %v", err)
 			}
 
 			def.Instructions = append(def.Instructions, Instruction{
@@ -135,19 +152,18 @@ func BenchmarkDefByRawInstructions(instructions string, igSize int) (BenchmarkDe
 					Interval: interval,
 				},
 			})
-			break
 		case instructionFloat:
 			if len(args) != 2 {
-				log.Logger().Fatalf("float requires 2 arguments: rate and duration")
+				return def, fmt.Errorf("float requires 2 arguments: rate and duration")
 			}
 			rate, err := strconv.ParseFloat(args[0], 64)
 			if err != nil {
-				log.Logger().Fatalf("%v", err)
+				return def, fmt.Errorf("float: %v", err)
 			}
 			var duration int
 			duration, err = strconv.Atoi(args[1])
 			if err != nil {
-				log.Logger().Fatalf("%v", err)
+				return def, fmt.Errorf("float: %v", err)
 			}
 
 			def.Instructions = append(def.Instructions, Instruction{
@@ -156,29 +172,30 @@ func BenchmarkDefByRawInstructions(instructions string, igSize int) (BenchmarkDe
 					Duration: duration,
 				},
 			})
-			break
 		case instructionSleep:
+			if len(args) != 1 {
+				return def, fmt.Errorf("sleep requires 1 argument: seconds")
+			}
 			timeout, err := strconv.Atoi(args[0])
 			if err != nil {
-				log.Logger().Fatalf("%v", err)
+				return def, fmt.Errorf("sleep: %v", err)
 			}
 
 			def.Instructions = append(def.Instructions, Instruction{
 				Sleep: timeout,
 			})
-			break
 		case instructionExpectRunning:
 			if len(args) != 2 {
-				log.Logger().Fatal("expect-running requires 2 arguments: [><] int")
+				return def, fmt.Errorf("expect-running requires 2 arguments: [><] int")
 			}
 
 			qty, err := strconv.Atoi(args[1])
 			if err != nil {
-				log.Logger().Fatalf("%v", err)
+				return def, fmt.Errorf("expect-running: %v", err)
 			}
 			symbol := ExpectRunningSymbol(args[0])
 			if symbol != Lower && symbol != Greater {
-				log.Logger().Fatalf("expect-running comparator has to be > or <")
+				return def, fmt.Errorf("expect-running comparator has to be > or <")
 			}
 
 			def.Instructions = append(def.Instructions, Instruction{
@@ -187,12 +204,12 @@ func BenchmarkDefByRawInstructions(instructions string, igSize int) (BenchmarkDe
 					Amount: qty,
 				},
 			})
-			break
-		case "stop-all":
+		case string(StopAll):
 			def.Instructions = append(def.Instructions, Instruction{
 				Stop: StopCommand(cmd),
 			})
-			break
+		default:
+			return def, fmt.Errorf("unknown instruction %q", cmd)
 		}
 	}
 
@@ -235,6 +252,19 @@ func validateDefinition(benchmark BenchmarkDef) bool {
 	if benchmark.Application.Image == "" && benchmark.Application.Type == "docker" && benchmark.Application.Type == "rkt" {
 		log.Logger().Warning("application image is empty using standard container")
 	}
+	switch benchmark.Application.Notifier {
+	case "", NotifierCurl, NotifierBash, NotifierNomi, NotifierState:
+	default:
+		log.Logger().Errorf("wrong application notifier %v, expected curl, bash, nomi or state", benchmark.Application.Notifier)
+		return false
+	}
+	if !validateMonitor(benchmark.Monitor) {
+		return false
+	}
+	if !validateAssertions(benchmark.Assertions) {
+		return false
+	}
+
 	emptyInstruction := &Instruction{}
 
 	for _, instruction := range benchmark.Instructions {
@@ -237,3 +237,33 @@ func TestApplicationUnitFileYAMLDefinition(t *testing.T) {
 		log.Fatalf("application unit file path is wrong %v expected %v", ins.Application.UnitFilePath, expected.UnitFilePath)
 	}
 }
+
+func TestParseMonitorProcesses(t *testing.T) {
+	processes, err := ParseMonitorProcesses("fleetd, docker,systemd=1,742")
+	if err != nil {
+		log.Fatalf("unexpected error: %v", err)
+	}
+	expected := []MonitoredProcess{
+		{Name: "fleetd"},
+		{Name: "docker"},
+		{Name: "systemd", PID: 1},
+		{Name: "pid-742", PID: 742},
+	}
+	if !reflect.DeepEqual(processes, expected) {
+		log.Fatalf("expected %v got %v", expected, processes)
+	}
+
+	for _, spec := range []string{"fleetd=x", "a b", "../etc"} {
+		if _, err := ParseMonitorProcesses(spec); err == nil {
+			log.Fatalf("expected an error parsing %q", spec)
+		}
+	}
+}
+
+func TestRawInstructionsErrors(t *testing.T) {
+	for _, raw := range []string{"(start 10)", "(sleep)", "(sleep ten)", "(expect-running = 3)", "(restart-all)"} {
+		if _, err := BenchmarkDefByRawInstructions(raw, 1); err == nil {
+			log.Fatalf("malformed instructions %q were accepted", raw)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package definition
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/nomi/log"
+)
+
+// DefaultMonitorInterval is the sampling interval of the monitored processes
+// in seconds
+const DefaultMonitorInterval = 10
+
+var processNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// MonitoredProcess is a process of the cluster machines whose resource usage
+// is collected during the benchmark. It is looked up by its command name,
+// unless a PID is given.
+type MonitoredProcess struct {
+	Name string `yaml:"name"`
+	PID  int    `yaml:"pid"`
+}
+
+type Monitor struct {
+	Interval  int                `yaml:"interval"`
+	Processes []MonitoredProcess `yaml:"processes"`
+}
+
+// DefaultMonitor monitors the daemons fleet relies on
+func DefaultMonitor() Monitor {
+	return Monitor{
+		Interval: DefaultMonitorInterval,
+		Processes: []MonitoredProcess{
+			{Name: "etcd"},
+			{Name: "fleetd"},
+			{Name: "systemd", PID: 1},
+		},
+	}
+}
+
+// WithDefaults fills the values of the monitor section left empty
+func (m Monitor) WithDefaults() Monitor {
+	if m.Interval <= 0 {
+		m.Interval = DefaultMonitorInterval
+	}
+	if len(m.Processes) == 0 {
+		m.Processes = DefaultMonitor().Processes
+	}
+	return m
+}
+
+// ParseMonitorProcesses parses a comma separated list of processes: command
+// names, PIDs or name=PID pairs, e.g. "fleetd,docker,journald,systemd=1"
+func ParseMonitorProcesses(spec string) ([]MonitoredProcess, error) {
+	processes := []MonitoredProcess{}
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		process := MonitoredProcess{Name: field}
+		if parts := strings.SplitN(field, "=", 2); len(parts) == 2 {
+			pid, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("wrong PID of process %q: %v", parts[0], err)
+			}
+			process = MonitoredProcess{Name: parts[0], PID: pid}
+		} else if pid, err := strconv.Atoi(field); err == nil {
+			process = MonitoredProcess{Name: "pid-" + field, PID: pid}
+		}
+
+		if !validProcess(process) {
+			return nil, fmt.Errorf("wrong monitored process %q", field)
+		}
+		processes = append(processes, process)
+	}
+	return processes, nil
+}
+
+// FormatMonitorProcesses is the inverse of ParseMonitorProcesses
+func FormatMonitorProcesses(processes []MonitoredProcess) string {
+	fields := []string{}
+	for _, process := range processes {
+		if process.PID > 0 {
+			fields = append(fields, fmt.Sprintf("%s=%d", process.Name, process.PID))
+		} else {
+			fields = append(fields, process.Name)
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+func validProcess(process MonitoredProcess) bool {
+	return processNameRegexp.MatchString(process.Name) && process.PID >= 0
+}
+
+func validateMonitor(monitor Monitor) bool {
+	if monitor.Interval < 0 {
+		log.Logger().Errorf("wrong monitor interval %d", monitor.Interval)
+		return false
+	}
+	for _, process := range monitor.Processes {
+		if !validProcess(process) {
+			log.Logger().Errorf("wrong monitored process %v", process)
+			return false
+		}
+	}
+	return true
+}
@@ -3,30 +3,28 @@
 package fleet
 
 import (
-	"net"
-	"net/http"
-	"net/url"
+	"fmt"
 	"strings"
-	"sync"
 
 	"github.com/coreos/fleet/client"
 	"github.com/coreos/fleet/schema"
+
+	"github.com/giantswarm/nomi/backend"
+	"github.com/giantswarm/nomi/log"
 )
 
 // fleetAPI represents all the operations we want to perform to a fleet API
 type fleetAPI interface {
-	StartUnit(unit schema.Unit) error
-	StartUnitGroup(unit []schema.Unit) error
-	ListUnits() ([]*schema.Unit, error)
-	CleanupPrefix(prefix string) error
-	Stop(unitName string) error
-	Destroy(unitName string) error
+	backend.Backend
+	backend.UnitStateLister
+	backend.MachineLister
 	Unload(unitName string) error
 }
 
+// fleetClient performs the operations through its own connection to the fleet
+// API. It is safe for concurrent use.
 type fleetClient struct {
 	api client.API
-	m   *sync.Mutex
 }
 
 // ListUnits returns the list of units in the fleet cluster
@@ -34,26 +32,52 @@ func (f *fleetClient) ListUnits() ([]*schema.Unit, error) {
 	return f.api.Units()
 }
 
-// StartUnitGroup starts an instance group by passing as input argument the instance group
-func (f *fleetClient) StartUnitGroup(units []schema.Unit) error {
-	f.m.Lock()
-	defer f.m.Unlock()
+// UnitStates returns the systemd states of the units in the fleet cluster
+func (f *fleetClient) UnitStates() ([]*schema.UnitState, error) {
+	return f.api.UnitStates()
+}
+
+// Machines returns the machines of the fleet cluster
+func (f *fleetClient) Machines() ([]backend.Machine, error) {
+	states, err := f.api.Machines()
+	if err != nil {
+		return nil, err
+	}
 
+	machines := []backend.Machine{}
+	for _, state := range states {
+		machines = append(machines, backend.Machine{
+			ID:       state.ID,
+			PublicIP: state.PublicIP,
+			Metadata: state.Metadata,
+			Version:  state.Version,
+		})
+	}
+	return machines, nil
+}
+
+// StartUnitGroup starts an instance group by passing as input argument the
+// instance group. When a request fails, the units of the group created so far
+// are destroyed.
+func (f *fleetClient) StartUnitGroup(units []schema.Unit) error {
+	created := []string{}
 	for _, unit := range units {
 		err := f.api.CreateUnit(&unit)
 		if err != nil {
-			return err
+			return f.rollback(created, err)
 		}
+		created = append(created, unit.Name)
+
 		err = f.api.SetUnitTargetState(unit.Name, "loaded")
 		if err != nil {
-			return err
+			return f.rollback(created, err)
 		}
 	}
 
 	for _, unit := range units {
 		err := f.api.SetUnitTargetState(unit.Name, "launched")
 		if err != nil {
-			return err
+			return f.rollback(created, err)
 		}
 	}
 	return nil
@@ -61,30 +85,21 @@ func (f *fleetClient) StartUnitGroup(units []schema.Unit) error {
 
 // StartUnit starts a specific unit in the cluster
 func (f *fleetClient) StartUnit(unit schema.Unit) error {
-	f.m.Lock()
-	defer f.m.Unlock()
-
-	err := f.api.CreateUnit(&unit)
-	if err != nil {
-		return err
-	}
-
-	err = f.api.SetUnitTargetState(unit.Name, "loaded")
-	if err != nil {
-		return err
-	}
+	return f.StartUnitGroup([]schema.Unit{unit})
+}
 
-	err = f.api.SetUnitTargetState(unit.Name, "launched")
-	if err != nil {
-		return err
+// rollback destroys the units of a group which could not be started
+func (f *fleetClient) rollback(created []string, cause error) error {
+	for _, name := range created {
+		if err := f.api.DestroyUnit(name); err != nil {
+			log.Logger().Warningf("unable to roll back unit %s: %v", name, err)
+		}
 	}
-	return nil
+	return fmt.Errorf("unable to start unit group, %d units rolled back: %v", len(created), cause)
 }
 
 // CleanupPrefix destroys all units with a specific prefix
 func (f *fleetClient) CleanupPrefix(prefix string) error {
-	f.m.Lock()
-	defer f.m.Unlock()
 	units, err := f.api.Units()
 	if err != nil {
 		return err
@@ -101,43 +116,45 @@ func (f *fleetClient) CleanupPrefix(prefix string) error {
 
 // Stop stops an unit by passing its unit name
 func (f *fleetClient) Stop(unitName string) error {
-	f.m.Lock()
-	defer f.m.Unlock()
 	return f.api.SetUnitTargetState(unitName, "loaded")
 }
 
 // Unload unloads an unit by passing its unit name
 func (f *fleetClient) Unload(unitName string) error {
-	f.m.Lock()
-	defer f.m.Unlock()
 	return f.api.SetUnitTargetState(unitName, "inactive")
 }
 
 // Destroy destroys an unit by passing its unit name
 func (f *fleetClient) Destroy(unitName string) error {
-	f.m.Lock()
-	defer f.m.Unlock()
 	return f.api.DestroyUnit(unitName)
 }
 
-func newFleet() *fleetClient {
-
-	cl := &http.Client{
-		Transport: &http.Transport{
-			Dial: func(string, string) (net.Conn, error) {
-				return net.Dial("unix", "/var/run/fleet.sock")
-			},
-		},
+// newFleet creates a client whose requests take one of slots and wait for the
+// limiter, when set
+func newFleet(config Config, dial dialFunc, limiter *tokenBucket, slots chan struct{}) (*fleetClient, error) {
+	httpClient, endpoint, err := newHTTPClient(config, dial)
+	if err != nil {
+		return nil, err
 	}
 
-	url, err := url.Parse("http://localhost/")
+	api, err := client.NewHTTPClient(httpClient, endpoint)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	api, err := client.NewHTTPClient(cl, *url)
-	if err != nil {
-		panic(err)
+
+	if config.Recorder != nil {
+		api = &instrumentedAPI{api, config.Recorder}
+	}
+	api = &concurrencyLimitedAPI{api, slots}
+	if limiter != nil {
+		api = &rateLimitedAPI{api, limiter}
+	}
+	api = &retryingAPI{
+		api:      api,
+		retries:  config.Retries,
+		backoff:  config.RetryBackoff,
+		recorder: config.Recorder,
 	}
 
-	return &fleetClient{api, new(sync.Mutex)}
+	return &fleetClient{api}, nil
 }
@@ -0,0 +1,154 @@
+package fleet
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/fleet/ssh"
+)
+
+const (
+	// DefaultEndpoint is the fleet API socket available on every cluster node
+	DefaultEndpoint = "unix:///var/run/fleet.sock"
+
+	defaultSSHUser = "core"
+	defaultSSHPort = "22"
+	sshDialTimeout = 10 * time.Second
+)
+
+// Config describes how to reach the fleet API
+type Config struct {
+	// Endpoint is the location of the fleet API: unix://<socket path> or
+	// http(s)://<host>:<port>
+	Endpoint string
+
+	// CAFile, CertFile and KeyFile configure TLS for https endpoints
+	CAFile   string
+	CertFile string
+	KeyFile  string
+
+	// RequestTimeout limits every request to the fleet API. Zero means no limit.
+	RequestTimeout time.Duration
+	// QPS limits the requests per second to the fleet API of the whole pool.
+	// Zero means no limit.
+	QPS float64
+	// Retries is the maximum amount of times a request failing with a
+	// transient error is sent again, waiting RetryBackoff before the first
+	// retry and doubling it afterwards
+	Retries      int
+	RetryBackoff time.Duration
+	// Recorder is notified about retried and failed requests
+	Recorder Recorder
+
+	// Tunnel is the [user@]host[:port] of a cluster node used to reach the
+	// endpoint through SSH, as fleetctl does
+	Tunnel                string
+	KnownHostsFile        string
+	StrictHostKeyChecking bool
+}
+
+type dialFunc func(network, addr string) (net.Conn, error)
+
+// newTunnel opens the SSH connection shared by all the clients of a pool
+func newTunnel(config Config) (dialFunc, error) {
+	if config.Tunnel == "" {
+		return net.Dial, nil
+	}
+
+	user, host := defaultSSHUser, config.Tunnel
+	if at := strings.Index(host, "@"); at >= 0 {
+		user, host = host[:at], host[at+1:]
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, defaultSSHPort)
+	}
+
+	var checker *ssh.HostKeyChecker
+	if config.StrictHostKeyChecking {
+		checker = ssh.NewHostKeyChecker(ssh.NewHostKeyFile(config.KnownHostsFile))
+	}
+
+	sshClient, err := ssh.NewSSHClient(user, host, checker, false, sshDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open ssh tunnel to %s: %v", host, err)
+	}
+	return func(network, addr string) (net.Conn, error) {
+		// Like fleetctl, the socket is reached by running fleetctl fd-forward
+		// on the node, forwarding unix sockets is often disabled in sshd
+		if network == "unix" {
+			return ssh.DialCommand(sshClient, fmt.Sprintf("fleetctl fd-forward %s", addr))
+		}
+		return sshClient.Dial(network, addr)
+	}, nil
+}
+
+// newHTTPClient returns the http client and the URL to talk to the configured
+// fleet API endpoint, dialing connections through the given function
+func newHTTPClient(config Config, dial dialFunc) (*http.Client, url.URL, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, url.URL{}, fmt.Errorf("wrong fleet endpoint %q: %v", config.Endpoint, err)
+	}
+
+	transport := &http.Transport{}
+	switch endpoint.Scheme {
+	case "unix", "file":
+		if endpoint.Path == "" {
+			return nil, url.URL{}, fmt.Errorf("wrong fleet endpoint %q: missing socket path", config.Endpoint)
+		}
+		sockPath := endpoint.Path
+		transport.Dial = func(string, string) (net.Conn, error) {
+			return dial("unix", sockPath)
+		}
+		// The host is ignored when dialing the socket
+		endpoint = &url.URL{Scheme: "http", Host: "localhost", Path: "/"}
+	case "http", "https":
+		transport.Dial = dial
+		if endpoint.Scheme == "https" {
+			transport.TLSClientConfig, err = tlsConfig(config)
+			if err != nil {
+				return nil, url.URL{}, err
+			}
+		}
+	default:
+		return nil, url.URL{}, fmt.Errorf("unsupported fleet endpoint scheme %q", endpoint.Scheme)
+	}
+
+	return &http.Client{Transport: transport, Timeout: config.RequestTimeout}, *endpoint, nil
+}
+
+func tlsConfig(config Config) (*tls.Config, error) {
+	cfg := &tls.Config{}
+
+	if config.CAFile != "" {
+		pem, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %v", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("unable to parse CA file " + config.CAFile)
+		}
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		if config.CertFile == "" || config.KeyFile == "" {
+			return nil, errors.New("client certificate and key files are required together")
+		}
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
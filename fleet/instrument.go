@@ -0,0 +1,69 @@
+package fleet
+
+import (
+	"time"
+
+	"github.com/coreos/fleet/client"
+	"github.com/coreos/fleet/machine"
+	"github.com/coreos/fleet/schema"
+)
+
+// instrumentedAPI times every request sent to the fleet API and reports it to
+// the recorder. Retried requests are reported once per attempt.
+type instrumentedAPI struct {
+	api      client.API
+	recorder Recorder
+}
+
+func (i *instrumentedAPI) record(op string, start time.Time, err error) {
+	i.recorder.RecordAPICall(op, time.Since(start), err)
+}
+
+func (i *instrumentedAPI) Machines() ([]machine.MachineState, error) {
+	start := time.Now()
+	machines, err := i.api.Machines()
+	i.record("Machines", start, err)
+	return machines, err
+}
+
+func (i *instrumentedAPI) Unit(name string) (*schema.Unit, error) {
+	start := time.Now()
+	unit, err := i.api.Unit(name)
+	i.record("Unit", start, err)
+	return unit, err
+}
+
+func (i *instrumentedAPI) Units() ([]*schema.Unit, error) {
+	start := time.Now()
+	units, err := i.api.Units()
+	i.record("Units", start, err)
+	return units, err
+}
+
+func (i *instrumentedAPI) UnitStates() ([]*schema.UnitState, error) {
+	start := time.Now()
+	states, err := i.api.UnitStates()
+	i.record("UnitStates", start, err)
+	return states, err
+}
+
+func (i *instrumentedAPI) SetUnitTargetState(name, target string) error {
+	start := time.Now()
+	err := i.api.SetUnitTargetState(name, target)
+	i.record("SetUnitTargetState", start, err)
+	return err
+}
+
+func (i *instrumentedAPI) CreateUnit(unit *schema.Unit) error {
+	start := time.Now()
+	err := i.api.CreateUnit(unit)
+	i.record("CreateUnit", start, err)
+	return err
+}
+
+func (i *instrumentedAPI) DestroyUnit(name string) error {
+	start := time.Now()
+	err := i.api.DestroyUnit(name)
+	i.record("DestroyUnit", start, err)
+	return err
+}
@@ -1,21 +1,77 @@
 package fleet
 
-import "github.com/coreos/fleet/schema"
+import (
+	"net"
+	"sync/atomic"
 
+	"github.com/coreos/fleet/schema"
+
+	"github.com/giantswarm/nomi/backend"
+)
+
+// fleetPool spreads the operations over several connections to the fleet API.
+// It is safe for concurrent use.
 type fleetPool struct {
 	fleets   []fleetAPI
-	nextConn int
+	nextConn uint64
+
+	// poller has a connection of its own, which sends one request at a time
+	// and does not record them
+	poller fleetAPI
+
+	// dial connects through the tunnel, when one is set
+	dial dialFunc
 }
 
 // NewFleetPool creats a pool of connections to a remote fleet API
-func NewFleetPool(size int) fleetAPI {
-	fleets := make([]fleetAPI, size)
+func NewFleetPool(size int, config Config) (fleetAPI, error) {
+	dial, err := newTunnel(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if size <= 0 {
+		size = 1
+	}
+
+	// All the connections share the same budget of requests per second
+	var limiter *tokenBucket
+	if config.QPS > 0 {
+		limiter = newTokenBucket(config.QPS)
+	}
+
+	// At most size requests are in flight over all the connections, each
+	// of them would otherwise open as many as it is given requests
+	slots := newSlots(size)
 
+	fleets := make([]fleetAPI, size)
 	for i := 0; i < size; i++ {
-		fleets[i] = newFleet()
+		fleets[i], err = newFleet(config, dial, limiter, slots)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	unrecorded := config
+	unrecorded.Recorder = nil
+	poller, err := newFleet(unrecorded, dial, limiter, newSlots(1))
+	if err != nil {
+		return nil, err
 	}
 
-	return &fleetPool{fleets, 0}
+	return &fleetPool{fleets: fleets, poller: poller, dial: dial}, nil
+}
+
+// Dial connects to a service of the cluster like the connections to the fleet
+// API do, through the SSH tunnel when one is set
+func (p *fleetPool) Dial(network, addr string) (net.Conn, error) {
+	return p.dial(network, addr)
+}
+
+// Poller returns the connection used to poll the units and their states, so
+// that the polling does not weigh on the latencies of the benchmark requests
+func (p *fleetPool) Poller() backend.Backend {
+	return p.poller
 }
 
 // StartUnitGroup starts an unit group
@@ -53,8 +109,17 @@ func (p *fleetPool) ListUnits() ([]*schema.Unit, error) {
 	return p.getFleetClient().ListUnits()
 }
 
+// UnitStates returns the systemd states of the units in the fleet cluster
+func (p *fleetPool) UnitStates() ([]*schema.UnitState, error) {
+	return p.getFleetClient().UnitStates()
+}
+
+// Machines returns the machines of the fleet cluster
+func (p *fleetPool) Machines() ([]backend.Machine, error) {
+	return p.getFleetClient().Machines()
+}
+
 func (p *fleetPool) getFleetClient() fleetAPI {
-	f := p.fleets[p.nextConn]
-	p.nextConn = (p.nextConn + 1) % len(p.fleets)
-	return f
+	next := atomic.AddUint64(&p.nextConn, 1)
+	return p.fleets[next%uint64(len(p.fleets))]
 }
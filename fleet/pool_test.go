@@ -0,0 +1,78 @@
+package fleet
+
+import (
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/fleet/client"
+)
+
+// busyAPI tracks how many of its requests are in flight at most
+type busyAPI struct {
+	client.API
+	mu          *sync.Mutex
+	inFlight    *int
+	maxInFlight *int
+}
+
+func (b *busyAPI) DestroyUnit(string) error {
+	b.mu.Lock()
+	*b.inFlight++
+	if *b.inFlight > *b.maxInFlight {
+		*b.maxInFlight = *b.inFlight
+	}
+	b.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	b.mu.Lock()
+	*b.inFlight--
+	b.mu.Unlock()
+	return nil
+}
+
+func TestPollerIsNotRecorded(t *testing.T) {
+	recorder := &countingRecorder{map[string]int{}, map[string]int{}}
+	pool, err := NewFleetPool(2, Config{Endpoint: DefaultEndpoint, Recorder: recorder})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	recorded := pool.(*fleetPool).fleets[0].(*fleetClient).api.(*retryingAPI).api.(*concurrencyLimitedAPI)
+	if _, instrumented := recorded.api.(*instrumentedAPI); !instrumented {
+		log.Fatalf("the requests of the benchmark are not recorded")
+	}
+	poller := pool.(*fleetPool).Poller().(*fleetClient).api.(*retryingAPI)
+	if _, instrumented := poller.api.(*concurrencyLimitedAPI).api.(*instrumentedAPI); instrumented || poller.recorder != nil {
+		log.Fatalf("the requests of the poller are recorded")
+	}
+}
+
+func TestPoolLimitsConcurrency(t *testing.T) {
+	pool, err := NewFleetPool(3, Config{Endpoint: DefaultEndpoint})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mu, inFlight, maxInFlight := new(sync.Mutex), 0, 0
+	for _, f := range pool.(*fleetPool).fleets {
+		limited := f.(*fleetClient).api.(*retryingAPI).api.(*concurrencyLimitedAPI)
+		limited.api = &busyAPI{mu: mu, inFlight: &inFlight, maxInFlight: &maxInFlight}
+	}
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			pool.Destroy("unit")
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	if maxInFlight != 3 {
+		log.Fatalf("expected 3 requests in flight at most, got: %d", maxInFlight)
+	}
+}
@@ -0,0 +1,142 @@
+package fleet
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/coreos/fleet/client"
+	"github.com/coreos/fleet/machine"
+	"github.com/coreos/fleet/schema"
+)
+
+// tokenBucket limits the rate of the requests to the fleet API. The bucket is
+// refilled with qps tokens per second and holds up to one second of tokens.
+type tokenBucket struct {
+	qps    float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	mu *sync.Mutex
+}
+
+func newTokenBucket(qps float64) *tokenBucket {
+	burst := math.Max(1, math.Floor(qps))
+	return &tokenBucket{
+		qps:    qps,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+		mu:     new(sync.Mutex),
+	}
+}
+
+// Wait blocks until a token is available and takes it
+func (b *tokenBucket) Wait() {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.qps)
+	b.last = now
+	// The token is taken right away, callers queue up by going into debt
+	b.tokens--
+	wait := time.Duration(0)
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / b.qps * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	time.Sleep(wait)
+}
+
+// rateLimitedAPI waits for a token of the bucket before every request
+type rateLimitedAPI struct {
+	api     client.API
+	limiter *tokenBucket
+}
+
+func (r *rateLimitedAPI) Machines() ([]machine.MachineState, error) {
+	r.limiter.Wait()
+	return r.api.Machines()
+}
+
+func (r *rateLimitedAPI) Unit(name string) (*schema.Unit, error) {
+	r.limiter.Wait()
+	return r.api.Unit(name)
+}
+
+func (r *rateLimitedAPI) Units() ([]*schema.Unit, error) {
+	r.limiter.Wait()
+	return r.api.Units()
+}
+
+func (r *rateLimitedAPI) UnitStates() ([]*schema.UnitState, error) {
+	r.limiter.Wait()
+	return r.api.UnitStates()
+}
+
+func (r *rateLimitedAPI) SetUnitTargetState(name, target string) error {
+	r.limiter.Wait()
+	return r.api.SetUnitTargetState(name, target)
+}
+
+func (r *rateLimitedAPI) CreateUnit(unit *schema.Unit) error {
+	r.limiter.Wait()
+	return r.api.CreateUnit(unit)
+}
+
+func (r *rateLimitedAPI) DestroyUnit(name string) error {
+	r.limiter.Wait()
+	return r.api.DestroyUnit(name)
+}
+
+// concurrencyLimitedAPI takes one of the slots shared by the connections for
+// every request, so that no more requests than slots are in flight at once
+type concurrencyLimitedAPI struct {
+	api   client.API
+	slots chan struct{}
+}
+
+func newSlots(size int) chan struct{} {
+	return make(chan struct{}, size)
+}
+
+func (c *concurrencyLimitedAPI) acquire() func() {
+	c.slots <- struct{}{}
+	return func() { <-c.slots }
+}
+
+func (c *concurrencyLimitedAPI) Machines() ([]machine.MachineState, error) {
+	defer c.acquire()()
+	return c.api.Machines()
+}
+
+func (c *concurrencyLimitedAPI) Unit(name string) (*schema.Unit, error) {
+	defer c.acquire()()
+	return c.api.Unit(name)
+}
+
+func (c *concurrencyLimitedAPI) Units() ([]*schema.Unit, error) {
+	defer c.acquire()()
+	return c.api.Units()
+}
+
+func (c *concurrencyLimitedAPI) UnitStates() ([]*schema.UnitState, error) {
+	defer c.acquire()()
+	return c.api.UnitStates()
+}
+
+func (c *concurrencyLimitedAPI) SetUnitTargetState(name, target string) error {
+	defer c.acquire()()
+	return c.api.SetUnitTargetState(name, target)
+}
+
+func (c *concurrencyLimitedAPI) CreateUnit(unit *schema.Unit) error {
+	defer c.acquire()()
+	return c.api.CreateUnit(unit)
+}
+
+func (c *concurrencyLimitedAPI) DestroyUnit(name string) error {
+	defer c.acquire()()
+	return c.api.DestroyUnit(name)
+}
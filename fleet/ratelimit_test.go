@@ -0,0 +1,29 @@
+package fleet
+
+import (
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	limiter := newTokenBucket(50)
+
+	start := time.Now()
+	wg := new(sync.WaitGroup)
+	// the first 50 requests use the initial burst, the next 25 need half a second
+	for i := 0; i < 75; i++ {
+		wg.Add(1)
+		go func() {
+			limiter.Wait()
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	if elapsed < 450*time.Millisecond || elapsed > 2*time.Second {
+		log.Fatalf("wrong rate limiting expected ~500ms got: %v", elapsed)
+	}
+}
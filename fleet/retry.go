@@ -0,0 +1,185 @@
+package fleet
+
+import (
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/coreos/fleet/client"
+	"github.com/coreos/fleet/machine"
+	"github.com/coreos/fleet/schema"
+)
+
+const maxRetryBackoff = 10 * time.Second
+
+var (
+	serverErrorRegexp = regexp.MustCompile(`Error 5\d\d`)
+	clientErrorRegexp = regexp.MustCompile(`Error 4\d\d`)
+	conflictRegexp    = regexp.MustCompile(`Error 409|already exists`)
+
+	// etcdErrorRegexp matches the errors of an etcd cluster electing a leader
+	// or too slow to answer, relayed by the fleet API
+	etcdErrorRegexp = regexp.MustCompile(`etcd(server)?: (request timed out|leader changed|no leader)|etcd cluster is unavailable|unable to communicate with etcd`)
+
+	// transientMessages are looked up in the errors which only carry their
+	// message anymore
+	transientMessages = []string{
+		"connection refused",
+		"connection reset",
+		"broken pipe",
+		"client.timeout exceeded",
+		"i/o timeout",
+	}
+)
+
+// Recorder collects the outcome of the requests to the fleet API
+type Recorder interface {
+	// RecordAPIRetry is called every time a failed request is retried
+	RecordAPIRetry(op string)
+	// RecordAPIFailure is called when a request fails for good
+	RecordAPIFailure(op string)
+	// RecordAPICall is called after every request sent to the fleet API
+	RecordAPICall(op string, latency time.Duration, err error)
+}
+
+// isTransient tells whether a request that failed with err may succeed when
+// it is sent again: timeouts, refused or dropped connections, 5xx responses
+// and etcd timeouts or leader elections. A missing socket, a denied permission,
+// an unknown host or a 4xx response are permanent.
+func isTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return true
+	}
+	switch cause(err) {
+	case io.EOF, io.ErrUnexpectedEOF, syscall.ECONNREFUSED, syscall.ECONNRESET, syscall.EPIPE:
+		return true
+	}
+
+	if clientErrorRegexp.MatchString(err.Error()) {
+		return false
+	}
+	if serverErrorRegexp.MatchString(err.Error()) || etcdErrorRegexp.MatchString(err.Error()) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, transient := range transientMessages {
+		if strings.Contains(msg, transient) {
+			return true
+		}
+	}
+	return false
+}
+
+// cause returns the error at the root of the errors of the http client and of
+// the net package
+func cause(err error) error {
+	for {
+		switch e := err.(type) {
+		case *url.Error:
+			err = e.Err
+		case *net.OpError:
+			err = e.Err
+		case *os.SyscallError:
+			err = e.Err
+		default:
+			return err
+		}
+	}
+}
+
+// retryingAPI sends again the requests that fail with transient errors,
+// waiting an exponentially growing backoff between attempts
+type retryingAPI struct {
+	api      client.API
+	retries  int
+	backoff  time.Duration
+	recorder Recorder
+}
+
+func (r *retryingAPI) do(op string, request func(attempt int) error) error {
+	backoff := r.backoff
+	for attempt := 0; ; attempt++ {
+		err := request(attempt)
+		if err == nil {
+			return nil
+		}
+		if attempt >= r.retries || !isTransient(err) {
+			if r.recorder != nil {
+				r.recorder.RecordAPIFailure(op)
+			}
+			return err
+		}
+
+		if r.recorder != nil {
+			r.recorder.RecordAPIRetry(op)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+func (r *retryingAPI) Machines() (machines []machine.MachineState, err error) {
+	err = r.do("Machines", func(int) error {
+		machines, err = r.api.Machines()
+		return err
+	})
+	return machines, err
+}
+
+func (r *retryingAPI) Unit(name string) (unit *schema.Unit, err error) {
+	err = r.do("Unit", func(int) error {
+		unit, err = r.api.Unit(name)
+		return err
+	})
+	return unit, err
+}
+
+func (r *retryingAPI) Units() (units []*schema.Unit, err error) {
+	err = r.do("Units", func(int) error {
+		units, err = r.api.Units()
+		return err
+	})
+	return units, err
+}
+
+func (r *retryingAPI) UnitStates() (states []*schema.UnitState, err error) {
+	err = r.do("UnitStates", func(int) error {
+		states, err = r.api.UnitStates()
+		return err
+	})
+	return states, err
+}
+
+func (r *retryingAPI) SetUnitTargetState(name, target string) error {
+	return r.do("SetUnitTargetState", func(int) error {
+		return r.api.SetUnitTargetState(name, target)
+	})
+}
+
+func (r *retryingAPI) CreateUnit(unit *schema.Unit) error {
+	return r.do("CreateUnit", func(attempt int) error {
+		err := r.api.CreateUnit(unit)
+		// A previous attempt may have created the unit before failing
+		if err != nil && attempt > 0 && conflictRegexp.MatchString(err.Error()) {
+			return nil
+		}
+		return err
+	})
+}
+
+func (r *retryingAPI) DestroyUnit(name string) error {
+	return r.do("DestroyUnit", func(int) error {
+		return r.api.DestroyUnit(name)
+	})
+}
@@ -0,0 +1,107 @@
+package fleet
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/coreos/fleet/client"
+	"github.com/coreos/fleet/schema"
+)
+
+type countingRecorder struct {
+	retries  map[string]int
+	failures map[string]int
+}
+
+func (r *countingRecorder) RecordAPIRetry(op string)                   { r.retries[op]++ }
+func (r *countingRecorder) RecordAPIFailure(op string)                 { r.failures[op]++ }
+func (r *countingRecorder) RecordAPICall(string, time.Duration, error) {}
+
+type flakyAPI struct {
+	client.API
+	errs  []error
+	calls int
+}
+
+func (f *flakyAPI) CreateUnit(*schema.Unit) error {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return f.errs[f.calls-1]
+	}
+	return nil
+}
+
+func TestIsTransient(t *testing.T) {
+	transient := []string{
+		"dial unix /var/run/fleet.sock: connect: connection refused",
+		"googleapi: Error 503: fleet server unable to communicate with etcd",
+		"net/http: request canceled (Client.Timeout exceeded while awaiting headers)",
+	}
+	for _, msg := range transient {
+		if !isTransient(errors.New(msg)) {
+			log.Fatalf("expected %q to be transient", msg)
+		}
+	}
+
+	permanent := []string{
+		"googleapi: Error 400: unable to parse unit",
+		"googleapi: Error 409: unit already exists",
+		"googleapi: Error 400: invalid etcd key",
+	}
+	for _, msg := range permanent {
+		if isTransient(errors.New(msg)) {
+			log.Fatalf("expected %q not to be transient", msg)
+		}
+	}
+
+	dial := func(errno syscall.Errno) error {
+		return &url.Error{Op: "Get", URL: "http://localhost/fleet/v1/units", Err: &net.OpError{
+			Op: "dial", Net: "unix", Err: &os.SyscallError{Syscall: "connect", Err: errno},
+		}}
+	}
+	if !isTransient(dial(syscall.ECONNREFUSED)) || !isTransient(dial(syscall.ECONNRESET)) {
+		log.Fatalf("expected refused and reset connections to be transient")
+	}
+	if isTransient(dial(syscall.ENOENT)) || isTransient(dial(syscall.EACCES)) {
+		log.Fatalf("expected a missing socket or a denied permission not to be transient")
+	}
+	if isTransient(&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "fleet.example"}}) {
+		log.Fatalf("expected an unknown host not to be transient")
+	}
+	if !isTransient(&net.DNSError{Err: "i/o timeout", Name: "fleet.example", IsTimeout: true}) {
+		log.Fatalf("expected a timeout to be transient")
+	}
+}
+
+func TestRetryingAPI(t *testing.T) {
+	recorder := &countingRecorder{map[string]int{}, map[string]int{}}
+
+	// The second attempt created the unit but its response got lost
+	api := &flakyAPI{errs: []error{
+		errors.New("connection refused"),
+		errors.New("read tcp 10.0.0.1:4002: i/o timeout"),
+		errors.New("googleapi: Error 409: unit already exists"),
+	}}
+	retrying := &retryingAPI{api, 5, time.Millisecond, recorder}
+	if err := retrying.CreateUnit(&schema.Unit{Name: "a.service"}); err != nil {
+		log.Fatalf("unexpected error: %v", err)
+	}
+	if recorder.retries["CreateUnit"] != 2 || recorder.failures["CreateUnit"] != 0 {
+		log.Fatalf("unexpected counts: %v %v", recorder.retries, recorder.failures)
+	}
+
+	api = &flakyAPI{errs: []error{errors.New("googleapi: Error 400: bad unit")}}
+	retrying = &retryingAPI{api, 5, time.Millisecond, recorder}
+	if err := retrying.CreateUnit(&schema.Unit{Name: "b.service"}); err == nil {
+		log.Fatalf("expected permanent error")
+	}
+	if api.calls != 1 || recorder.failures["CreateUnit"] != 1 {
+		log.Fatalf("expected a single attempt, got %d", api.calls)
+	}
+}
@@ -0,0 +1,43 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/giantswarm/nomi/unit"
+)
+
+// printAppMetrics prints the aggregates of the metrics reported by the
+// benchmark units and the outcome of the assertions on them
+func printAppMetrics(stats unit.Stats, out io.Writer) {
+	if len(stats.AppMetricsTotal) > 0 {
+		names := []string{}
+		for name := range stats.AppMetricsTotal {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Fprintln(out, "-- Application metrics --")
+		for _, name := range names {
+			s := stats.AppMetricsTotal[name]
+			fmt.Fprintf(out, "%-24s %5d units %6d values  mean %.3f  min %.3f  max %.3f  sum %.3f\n", name, s.Units, s.Count, s.Mean, s.Min, s.Max, s.Sum)
+		}
+	}
+
+	if len(stats.Assertions) == 0 {
+		return
+	}
+	fmt.Fprintln(out, "-- Assertions --")
+	for _, result := range stats.Assertions {
+		outcome := "PASS"
+		if !result.Passed {
+			outcome = "FAIL"
+		}
+		actual := fmt.Sprintf("%.3f", result.Actual)
+		if !result.Measured {
+			actual = "not reported"
+		}
+		fmt.Fprintf(out, "[%s] %s(%s) %s %g: %s\n", outcome, result.Aggregate, result.Metric, result.Symbol, result.Value, actual)
+	}
+}
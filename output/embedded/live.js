@@ -0,0 +1,73 @@
+// live keeps the metrics of the benchmark in progress up to date with the
+// events streamed by the observer, and redraws the charts of script.js
+
+var allData = null;
+var dirty = false;
+
+var liveStatus = d3.select("#live-status");
+
+var append = function(key, value) {
+  allData[key] = (allData[key] || []).concat([value]);
+  dirty = true;
+};
+
+var source = new EventSource("/live/events");
+
+source.addEventListener("snapshot", function(e) {
+  allData = JSON.parse(e.data);
+  dirty = true;
+});
+
+source.addEventListener("start", function(e) {
+  append("Start", JSON.parse(e.data));
+});
+
+source.addEventListener("stop", function(e) {
+  append("Stop", JSON.parse(e.data));
+});
+
+source.addEventListener("failed", function(e) {
+  append("Failed", JSON.parse(e.data));
+});
+
+source.addEventListener("event", function(e) {
+  append("EventLog", JSON.parse(e.data));
+});
+
+source.addEventListener("machine-stats", function(e) {
+  allData.MachineStats = allData.MachineStats || {};
+  _.each(JSON.parse(e.data), function(lines, machine) {
+    allData.MachineStats[machine] = (allData.MachineStats[machine] || []).concat(lines);
+  });
+  dirty = true;
+});
+
+source.addEventListener("status", function(e) {
+  var status = JSON.parse(e.data);
+  if (allData) {
+    allData.Elapsed = status.Elapsed;
+    dirty = true;
+  }
+
+  var instruction = status.Finished ? (status.Aborted ? "benchmark aborted" : "benchmark finished") :
+    status.Paused ? "paused before instruction " + (status.Instruction.Index + 2) + "/" + status.Instruction.Total :
+    status.Instruction.Index < 0 ? "waiting for the benchmark to start" :
+    "instruction " + (status.Instruction.Index + 1) + "/" + status.Instruction.Total + ": " + status.Instruction.Description;
+  liveStatus.text(status.Elapsed.toFixed(1) + "s, " + instruction + " | units " +
+    status.Units.Starting + " starting, " + status.Units.Running + " running, " +
+    status.Units.Stopping + " stopping, " + status.Units.Stopped + " stopped, " +
+    status.Units.Failed + " failed | start delay p50 " + status.StartDelay.P50.toFixed(3) +
+    "s p90 " + status.StartDelay.P90.toFixed(3) + "s p99 " + status.StartDelay.P99.toFixed(3) + "s");
+});
+
+source.onerror = function() {
+  liveStatus.text("disconnected from nomi, reconnecting...");
+};
+
+// redraw at most once per second, the charts are drawn from scratch
+setInterval(function() {
+  if (allData && dirty) {
+    dirty = false;
+    render(allData);
+  }
+}, 1000);
@@ -1,286 +1,613 @@
-var starts = _.sortBy(allData.Start, function(d) {
-  return d.CompletionTime
-});
-
-var skippables = _.map(starts.slice(0, -1), function(d, i) {
-  return (starts[i + 1].CompletionTime - d.CompletionTime) > 20 ? i : 0;
-});
-var skippablesIdx = _.filter(skippables, function(d) {
-  return d > 0;
-});
-
-
-// generates title
-
-var events = _.sortBy(_.map(allData.EventLog,
-  function(ev) {
-    return {
-      cmd: ev.Cmd + " " + ((ev.Args != null) ? ev.Args.join(" ") : ""),
-      ts: ev.StartTime,
-      end: ev.EndTime
-    };
-  }), function(x) {return x.ts});
+// render draws the charts of the collected metrics into #content, replacing
+// the ones drawn before
+var render = function(allData) {
+  d3.select("#content").html("");
+  d3.selectAll("div.tooltip").remove();
+
+  var starts = _.sortBy(allData.Start || [], function(d) {
+    return d.CompletionTime
+  });
 
+  var skippables = _.map(starts.slice(0, -1), function(d, i) {
+    return (starts[i + 1].CompletionTime - d.CompletionTime) > 20 ? i : 0;
+  });
+  var skippablesIdx = _.filter(skippables, function(d) {
+    return d > 0;
+  });
 
 
-var title = d3.select("#content").append("h4")
+  // generates title
 
-var stringify = function(d) {
-  return _.flatten(_.map(_.keys(d), function(k) {
-    return [k, ": ", d[k], "<br/>"]
-  })).join("");
-}
+  var events = _.sortBy(_.map(allData.EventLog || [],
+    function(ev) {
+      return {
+        cmd: ev.Cmd + " " + ((ev.Args != null) ? ev.Args.join(" ") : ""),
+        ts: ev.StartTime,
+        end: ev.EndTime
+      };
+    }), function(x) {return x.ts});
 
-var broken = [];
 
-var lastBreak = 0
-_.each(starts.slice(0, -1),
-  function(d, i) {
-    if ((starts[i + 1].CompletionTime - d.CompletionTime) > 20) {
-      broken.push(starts.slice(lastBreak, i));
-      lastBreak = i
-    }
-  });
-broken.push(starts.slice(lastBreak, -1));
-
-var margin = {
-    top: 20,
-    right: 50,
-    bottom: 30,
-    left: 30
-  },
-  width = 960 - margin.left - margin.right,
-  height = 500 - margin.top - margin.bottom;
-
-var xScale = d3.scale.linear()
-  .domain([0, 1.01 * d3.max(allData.EventLog, function(d) {
-    return d.EndTime;
-  })])
-  .range([0, width]);
-
-var yScale = d3.scale.linear()
-  .domain([0, 1.01 * d3.max(starts, function(d) {
-    return d.Delay;
-  })])
-  .range([height, 0]);
-
-var yUnitScale = d3.scale.linear()
-  .domain([0, d3.max(starts, function(d) {
-    return d.RunningCount;
-  })])
-  .range([height, 0]);
-
-var yCPUScale = d3.scale.linear()
-  .domain([0, 100])
-  .range([height, 0]);
-
-var xAxis = d3.svg.axis()
-  .scale(xScale)
-  .orient("bottom")
-  .innerTickSize(-height)
-  .outerTickSize(0)
-  .tickPadding(10);
-
-var yAxis = d3.svg.axis()
-  .scale(yScale)
-  .ticks(10)
-  .orient("left")
-  .innerTickSize(-width)
-  .outerTickSize(0);
-
-var yUnitsAxis = d3.svg.axis()
-  .scale(yUnitScale)
-  .ticks(10)
-  .orient("right")
-  .innerTickSize(-width)
-  .outerTickSize(0);
-
-var canvas = d3.select("#content")
-  .append("svg")
-  .attr("width", width + margin.left + margin.right)
-  .attr("height", height + margin.top + margin.bottom)
-  .append("g")
-  .attr("transform", "translate(" + margin.left + "," + margin.top + ")");
-
-canvas.append("g")
-  .attr("class", "x axis")
-  .attr("transform", "translate(0," + height + ")")
-  .call(xAxis);
-
-canvas.append("g")
-  .attr("class", "y axis left")
-  .call(yAxis);
-
-canvas.append("g")
-  .attr("class", "y axis nogrid")
-  .attr("transform", "translate(" + width + ",0)")
-  .call(yUnitsAxis);
-
-
-var div = d3.select("body").append("div")
-  .attr("class", "tooltip")
-  .style("opacity", 0);
-
-var bars = canvas.selectAll("circles")
-  .data(allData.Start)
-  .enter()
-  .append("circle")
-  .attr("class", "delaypoint")
-  .attr("cy", function(d) {
-    return yScale(d.Delay);
-  })
-  .attr("r", function(d) {
-    return 2;
-  })
-  .attr("cx", function(d, i) {
-    return xScale(d.CompletionTime);
-  })
-  .on("mouseover", function(d) {
-    div.transition()
-      .duration(20)
-      .style("opacity", .9);
-    div.html(stringify(d))
-      .style("left", (d3.event.pageX) + "px")
-      .style("top", (d3.event.pageY - 28) + "px");
+
+  var title = d3.select("#content").append("h4")
+
+  var stringify = function(d) {
+    return _.flatten(_.map(_.keys(d), function(k) {
+      return [k, ": ", d[k], "<br/>"]
+    })).join("");
+  }
+
+  var broken = [];
+
+  var lastBreak = 0
+  _.each(starts.slice(0, -1),
+    function(d, i) {
+      if ((starts[i + 1].CompletionTime - d.CompletionTime) > 20) {
+        broken.push(starts.slice(lastBreak, i));
+        lastBreak = i
+      }
+    });
+  broken.push(starts.slice(lastBreak, -1));
+
+  var margin = {
+      top: 20,
+      right: 50,
+      bottom: 30,
+      left: 30
+    },
+    width = 960 - margin.left - margin.right,
+    height = 500 - margin.top - margin.bottom;
+
+  // a benchmark in progress has no instruction finished yet
+  var maxTime = d3.max([
+    d3.max(allData.EventLog || [], function(d) {
+      return d.EndTime;
+    }),
+    d3.max(starts, function(d) {
+      return d.CompletionTime;
+    }),
+    allData.Elapsed
+  ]) || 1;
+
+  var xScale = d3.scale.linear()
+    .domain([0, 1.01 * maxTime])
+    .range([0, width]);
+
+  var yScale = d3.scale.linear()
+    .domain([0, 1.01 * (d3.max(starts, function(d) {
+      return d.Delay;
+    }) || 1)])
+    .range([height, 0]);
+
+  var yUnitScale = d3.scale.linear()
+    .domain([0, d3.max(starts, function(d) {
+      return d.RunningCount;
+    }) || 1])
+    .range([height, 0]);
+
+  var yCPUScale = d3.scale.linear()
+    .domain([0, 100])
+    .range([height, 0]);
+
+  var xAxis = d3.svg.axis()
+    .scale(xScale)
+    .orient("bottom")
+    .innerTickSize(-height)
+    .outerTickSize(0)
+    .tickPadding(10);
+
+  var yAxis = d3.svg.axis()
+    .scale(yScale)
+    .ticks(10)
+    .orient("left")
+    .innerTickSize(-width)
+    .outerTickSize(0);
+
+  var yUnitsAxis = d3.svg.axis()
+    .scale(yUnitScale)
+    .ticks(10)
+    .orient("right")
+    .innerTickSize(-width)
+    .outerTickSize(0);
+
+  var canvas = d3.select("#content")
+    .append("svg")
+    .attr("width", width + margin.left + margin.right)
+    .attr("height", height + margin.top + margin.bottom)
+    .append("g")
+    .attr("transform", "translate(" + margin.left + "," + margin.top + ")");
+
+  canvas.append("g")
+    .attr("class", "x axis")
+    .attr("transform", "translate(0," + height + ")")
+    .call(xAxis);
+
+  canvas.append("g")
+    .attr("class", "y axis left")
+    .call(yAxis);
+
+  canvas.append("g")
+    .attr("class", "y axis nogrid")
+    .attr("transform", "translate(" + width + ",0)")
+    .call(yUnitsAxis);
+
+
+  var div = d3.select("body").append("div")
+    .attr("class", "tooltip")
+    .style("opacity", 0);
+
+  var bars = canvas.selectAll("circles")
+    .data(starts)
+    .enter()
+    .append("circle")
+    .attr("class", "delaypoint")
+    .attr("cy", function(d) {
+      return yScale(d.Delay);
+    })
+    .attr("r", function(d) {
+      return 2;
+    })
+    .attr("cx", function(d, i) {
+      return xScale(d.CompletionTime);
+    })
+    .on("mouseover", function(d) {
+      div.transition()
+        .duration(20)
+        .style("opacity", .9);
+      div.html(stringify(d))
+        .style("left", (d3.event.pageX) + "px")
+        .style("top", (d3.event.pageY - 28) + "px");
+    })
+    .on("mouseout", function(d) {
+      div.transition()
+        .duration(500)
+        .style("opacity", 0);
+    });
+
+  _.each(broken, function(data) {
+    var startingCount = canvas.append("path")
+      .data([data.slice(1, -1)])
+      .attr("class", "line-starting-count")
+      .attr("d", d3.svg.line()
+        .x(function(d) {
+          return xScale(d.CompletionTime);
+        })
+        .y(function(d) {
+          return yUnitScale(d.StartingCount);
+        })
+      );
   })
-  .on("mouseout", function(d) {
-    div.transition()
-      .duration(500)
-      .style("opacity", 0);
-  });
 
-_.each(broken, function(data) {
-  var startingCount = canvas.append("path")
-    .data([data.slice(1, -1)])
-    .attr("class", "line-starting-count")
+  var runningCount = canvas.append("path")
+    .data([starts])
+    .attr("class", "line-running-count")
     .attr("d", d3.svg.line()
       .x(function(d) {
         return xScale(d.CompletionTime);
       })
       .y(function(d) {
-        return yUnitScale(d.StartingCount);
+        return yUnitScale(d.RunningCount);
       })
     );
-})
 
-var runningCount = canvas.append("path")
-  .data([starts])
-  .attr("class", "line-running-count")
-  .attr("d", d3.svg.line()
-    .x(function(d) {
-      return xScale(d.CompletionTime);
-    })
-    .y(function(d) {
-      return yUnitScale(d.RunningCount);
-    })
-  );
+  var processes = _.uniq(_.flatten(_.map(allData.MachineStats, function(machineStats) {
+    return _.pluck(machineStats, "Process");
+  }))).sort();
+  var processColor = d3.scale.category10().domain(processes);
+
+  _.each(allData.MachineStats, function(machineStats, machineName) {
+    _.each(processes, function(process) {
+      var processLine = _.filter(machineStats, function(obj){ return obj.Process == process;});
+      canvas.append("path")
+        .data([processLine])
+        .attr("class", "process-cpu-usage")
+        .style("stroke", processColor(process))
+        .attr("d", d3.svg.line()
+            .x(function(d) {
+              return xScale(d.TimeStamp);
+            })
+            .y(function(d) {
+              return yCPUScale(d.CPUUsage);
+            })
+            );
+    });
+  })
 
-_.each(allData.MachineStats, function(machineStats, machineName) {
-  var systemdLine = _.filter(machineStats, function(obj){ return obj.Process == "systemd";});
-  canvas.append("path")
-    .data([systemdLine])
-    .attr("class", "systemd-cpu-usage")
-    .attr("d", d3.svg.line()
-        .x(function(d) {
-          return xScale(d.TimeStamp);
-        })
-        .y(function(d) {
-          return yCPUScale(d.CPUUsage);
-        })
-        );
-  var fleetLine = _.filter(machineStats, function(obj){ return obj.Process == "fleetd";});
-  canvas.append("path")
-    .data([fleetLine])
-    .attr("class", "fleet-cpu-usage")
-    .attr("d", d3.svg.line()
-        .x(function(d) {
-          return xScale(d.TimeStamp);
-        })
-        .y(function(d) {
-          return yCPUScale(d.CPUUsage);
-        })
-        );
-})
-
-
-// labels
-
-var xlabel = canvas.append("text")
-  .attr("transform", "translate(" + (width / 2) + " ," + (height + margin.bottom) + ")")
-  .style("text-anchor", "middle")
-  .text("test time (s)");
-
-var ylabel = canvas.append("text")
-  .attr("transform", "rotate(-90)")
-  .attr("y", 0 - margin.left)
-  .attr("x", 0 - (height / 2))
-  .attr("dy", "1em")
-  .style("text-anchor", "middle")
-  .text("delay (s)");
-
-var yUnitslabel = canvas.append("text")
-  .attr("transform", "rotate(-90)")
-  .attr("y", width + margin.right / 2)
-  .attr("x", 0 - (height / 2))
-  .attr("dy", "1em")
-  .style("text-anchor", "middle")
-  .text("unit count");
-
-var createLegend = function(g) {
-  var legend = g.append("g")
-    .attr("class", "legend")
-
-  legend.append("circle").attr("class", "delaypoint")
-    .attr("cx", width / 2).attr("cy", 0).attr("r", 2)
-
-  var createLabel = function(label, y_offset) {
-    return function(g) {
-      g.append("text")
-        .attr("x", width / 2 + 5)
-        .attr("y", y_offset)
-        .attr("dy", ".35em")
-        .style("text-anchor", "begin")
-        .text(label)
+
+  // labels
+
+  var xlabel = canvas.append("text")
+    .attr("transform", "translate(" + (width / 2) + " ," + (height + margin.bottom) + ")")
+    .style("text-anchor", "middle")
+    .text("test time (s)");
+
+  var ylabel = canvas.append("text")
+    .attr("transform", "rotate(-90)")
+    .attr("y", 0 - margin.left)
+    .attr("x", 0 - (height / 2))
+    .attr("dy", "1em")
+    .style("text-anchor", "middle")
+    .text("delay (s)");
+
+  var yUnitslabel = canvas.append("text")
+    .attr("transform", "rotate(-90)")
+    .attr("y", width + margin.right / 2)
+    .attr("x", 0 - (height / 2))
+    .attr("dy", "1em")
+    .style("text-anchor", "middle")
+    .text("unit count");
+
+  var createLegend = function(g) {
+    var legend = g.append("g")
+      .attr("class", "legend")
+
+    legend.append("circle").attr("class", "delaypoint")
+      .attr("cx", width / 2).attr("cy", 0).attr("r", 2)
+
+    var createLabel = function(label, y_offset) {
+      return function(g) {
+        g.append("text")
+          .attr("x", width / 2 + 5)
+          .attr("y", y_offset)
+          .attr("dy", ".35em")
+          .style("text-anchor", "begin")
+          .text(label)
+      };
     };
-  };
 
-  var createLegendLine = function(klass, y_offset) {
-    return function(g) {
-      g.append("line")
-        .attr("class", klass)
+    var createLegendLine = function(klass, y_offset) {
+      return function(g) {
+        g.append("line")
+          .attr("class", klass)
+          .attr("x1", width / 2 - 5)
+          .attr("x2", width / 2 + 3)
+          .attr("y1", y_offset)
+          .attr("y2", y_offset)
+      };
+    }
+
+    legend.call(createLabel("delay between star-trigger and real-start", 0));
+    legend.call(createLabel("units running", 15));
+    legend.call(createLabel("units starting", 30));
+    legend.call(createLegendLine("line-running-count", 15))
+    legend.call(createLegendLine("line-starting-count", 30))
+
+    _.each(processes, function(process, i) {
+      var y_offset = 45 + i * 15;
+      legend.call(createLabel(process + " cpu usage", y_offset));
+      legend.append("line")
+        .attr("class", "process-cpu-usage")
+        .style("stroke", processColor(process))
         .attr("x1", width / 2 - 5)
         .attr("x2", width / 2 + 3)
         .attr("y1", y_offset)
-        .attr("y2", y_offset)
-    };
+        .attr("y2", y_offset);
+    });
+  };
+
+  canvas.call(createLegend)
+
+  var eventLine = canvas.append("rect")
+    .attr("class","timeline-focus")
+    .attr("x", 0)
+    .attr("y", 0)
+    .attr("width", 0)
+    .attr("height", height)
+    .style("display","none");
+
+  _.each(events, function(ev) {
+    title
+      .append("span")
+      .attr("class","event-text")
+      .on("mouseover", function() { eventLine.style("display", null); })
+      .on("mouseout", function() { eventLine.style("display", "none"); })
+      .on("mousemove",function() {
+        eventLine.attr("x",xScale(ev.ts))
+        eventLine.attr("width", xScale(ev.end) - xScale(ev.ts))
+      })
+      .text(ev.cmd);
+  });
+
+  // API latency
+
+  var apiCalls = allData.APICalls || [];
+
+  if (apiCalls.length > 0) {
+    var apiOps = _.uniq(_.pluck(apiCalls, "Op")).sort();
+    var apiColor = d3.scale.category10().domain(apiOps);
+
+    d3.select("#content").append("h4").text("fleet API latency");
+
+    var apiCanvas = d3.select("#content")
+      .append("svg")
+      .attr("width", width + margin.left + margin.right)
+      .attr("height", height + margin.top + margin.bottom)
+      .append("g")
+      .attr("transform", "translate(" + margin.left + "," + margin.top + ")");
+
+    var xAPIScale = d3.scale.linear()
+      .domain([0, 1.01 * d3.max(apiCalls, function(d) {
+        return d.StartTime + d.Latency;
+      })])
+      .range([0, width]);
+
+    var yAPIScale = d3.scale.linear()
+      .domain([0, 1.01 * d3.max(apiCalls, function(d) {
+        return d.Latency;
+      })])
+      .range([height, 0]);
+
+    apiCanvas.append("g")
+      .attr("class", "x axis")
+      .attr("transform", "translate(0," + height + ")")
+      .call(d3.svg.axis()
+        .scale(xAPIScale)
+        .orient("bottom")
+        .innerTickSize(-height)
+        .outerTickSize(0)
+        .tickPadding(10));
+
+    apiCanvas.append("g")
+      .attr("class", "y axis")
+      .call(d3.svg.axis()
+        .scale(yAPIScale)
+        .ticks(10)
+        .orient("left")
+        .innerTickSize(-width)
+        .outerTickSize(0));
+
+    apiCanvas.selectAll("circles")
+      .data(apiCalls)
+      .enter()
+      .append("circle")
+      .attr("class", function(d) {
+        return d.Failed ? "apipoint failed" : "apipoint";
+      })
+      .attr("r", 2)
+      .attr("cx", function(d) {
+        return xAPIScale(d.StartTime);
+      })
+      .attr("cy", function(d) {
+        return yAPIScale(d.Latency);
+      })
+      .style("fill", function(d) {
+        return apiColor(d.Op);
+      })
+      .on("mouseover", function(d) {
+        div.transition()
+          .duration(20)
+          .style("opacity", .9);
+        div.html(stringify(d))
+          .style("left", (d3.event.pageX) + "px")
+          .style("top", (d3.event.pageY - 28) + "px");
+      })
+      .on("mouseout", function(d) {
+        div.transition()
+          .duration(500)
+          .style("opacity", 0);
+      });
+
+    apiCanvas.append("text")
+      .attr("transform", "translate(" + (width / 2) + " ," + (height + margin.bottom) + ")")
+      .style("text-anchor", "middle")
+      .text("test time (s)");
+
+    apiCanvas.append("text")
+      .attr("transform", "rotate(-90)")
+      .attr("y", 0 - margin.left)
+      .attr("x", 0 - (height / 2))
+      .attr("dy", "1em")
+      .style("text-anchor", "middle")
+      .text("latency (s)");
+
+    var apiLegend = apiCanvas.append("g")
+      .attr("class", "legend");
+
+    _.each(apiOps, function(op, i) {
+      apiLegend.append("circle")
+        .attr("cx", width / 2).attr("cy", i * 15).attr("r", 2)
+        .style("fill", apiColor(op));
+      apiLegend.append("text")
+        .attr("x", width / 2 + 5)
+        .attr("y", i * 15)
+        .attr("dy", ".35em")
+        .style("text-anchor", "begin")
+        .text(op);
+    });
   }
 
-  legend.call(createLabel("delay between star-trigger and real-start", 0));
-  legend.call(createLabel("units running", 15));
-  legend.call(createLabel("units starting", 30));
-  legend.call(createLegendLine("line-running-count", 15))
-  legend.call(createLegendLine("line-starting-count", 30))
-};
+  // start phases
 
-canvas.call(createLegend)
-
-var eventLine = canvas.append("rect")
-  .attr("class","timeline-focus")
-  .attr("x", 0)
-  .attr("y", 0)
-  .attr("width", 0)
-  .attr("height", height)
-  .style("display","none");
-
-_.each(events, function(ev) {
-  title
-    .append("span")
-    .attr("class","event-text")
-    .on("mouseover", function() { eventLine.style("display", null); })
-    .on("mouseout", function() { eventLine.style("display", "none"); })
-    .on("mousemove",function() {
-      eventLine.attr("x",xScale(ev.ts))
-      eventLine.attr("width", xScale(ev.end) - xScale(ev.ts))
-    })
-    .text(ev.cmd);
-});
+  var phaseNames = ["submission", "scheduling", "agent load", "systemd activation", "hello callback"];
+
+  var phaseDurations = function(d) {
+    var timestamps = [d.SubmittedTime, d.ScheduledTime, d.LoadedTime, d.ActiveTime, d.CompletionTime];
+    var previous = d.StartTime;
+    return _.map(timestamps, function(t) {
+      t = Math.min(Math.max(t, previous), d.CompletionTime);
+      var duration = t - previous;
+      previous = t;
+      return duration;
+    });
+  };
+
+  var phased = _.sortBy(_.filter(starts, function(d) {
+    return d.SubmittedTime > 0;
+  }), function(d) {
+    return d.StartTime;
+  });
+
+  if (phased.length > 0) {
+    var phaseColor = d3.scale.category10().domain(phaseNames);
+
+    d3.select("#content").append("h4").text("start phases");
+
+    var phaseCanvas = d3.select("#content")
+      .append("svg")
+      .attr("width", width + margin.left + margin.right)
+      .attr("height", height + margin.top + margin.bottom)
+      .append("g")
+      .attr("transform", "translate(" + margin.left + "," + margin.top + ")");
+
+    var xPhaseScale = d3.scale.ordinal()
+      .domain(d3.range(phased.length))
+      .rangeBands([0, width], 0.1);
+
+    var yPhaseScale = d3.scale.linear()
+      .domain([0, 1.01 * d3.max(phased, function(d) {
+        return d.CompletionTime - d.StartTime;
+      })])
+      .range([height, 0]);
+
+    phaseCanvas.append("g")
+      .attr("class", "y axis")
+      .call(d3.svg.axis()
+        .scale(yPhaseScale)
+        .ticks(10)
+        .orient("left")
+        .innerTickSize(-width)
+        .outerTickSize(0));
+
+    _.each(phased, function(d, i) {
+      var offset = 0;
+      _.each(phaseDurations(d), function(duration, phase) {
+        phaseCanvas.append("rect")
+          .attr("x", xPhaseScale(i))
+          .attr("width", xPhaseScale.rangeBand())
+          .attr("y", yPhaseScale(offset + duration))
+          .attr("height", yPhaseScale(offset) - yPhaseScale(offset + duration))
+          .style("fill", phaseColor(phaseNames[phase]))
+          .on("mouseover", function() {
+            div.transition()
+              .duration(20)
+              .style("opacity", .9);
+            div.html(stringify({
+                ID: d.ID,
+                Phase: phaseNames[phase],
+                Duration: duration
+              }))
+              .style("left", (d3.event.pageX) + "px")
+              .style("top", (d3.event.pageY - 28) + "px");
+          })
+          .on("mouseout", function() {
+            div.transition()
+              .duration(500)
+              .style("opacity", 0);
+          });
+        offset += duration;
+      });
+    });
+
+    phaseCanvas.append("text")
+      .attr("transform", "translate(" + (width / 2) + " ," + (height + margin.bottom) + ")")
+      .style("text-anchor", "middle")
+      .text("units by start request");
+
+    phaseCanvas.append("text")
+      .attr("transform", "rotate(-90)")
+      .attr("y", 0 - margin.left)
+      .attr("x", 0 - (height / 2))
+      .attr("dy", "1em")
+      .style("text-anchor", "middle")
+      .text("delay (s)");
+
+    var phaseLegend = phaseCanvas.append("g")
+      .attr("class", "legend");
+
+    _.each(phaseNames, function(phase, i) {
+      phaseLegend.append("rect")
+        .attr("x", width / 2 - 8).attr("y", i * 15 - 4)
+        .attr("width", 8).attr("height", 8)
+        .style("fill", phaseColor(phase));
+      phaseLegend.append("text")
+        .attr("x", width / 2 + 5)
+        .attr("y", i * 15)
+        .attr("dy", ".35em")
+        .style("text-anchor", "begin")
+        .text(phase);
+    });
+  }
+
+  // placement
+
+  var placement = allData.Placement || [];
+
+  if (placement.length > 0) {
+    d3.select("#content").append("h4")
+      .text("units per machine (imbalance " + allData.PlacementImbalance.toFixed(2) +
+        ", stddev " + allData.PlacementStddev.toFixed(2) + ")");
+
+    var placementCanvas = d3.select("#content")
+      .append("svg")
+      .attr("width", width + margin.left + margin.right)
+      .attr("height", height + margin.top + margin.bottom)
+      .append("g")
+      .attr("transform", "translate(" + margin.left + "," + margin.top + ")");
+
+    var xPlacementScale = d3.scale.ordinal()
+      .domain(_.map(placement, function(d) {
+        return d.Hostname || d.MachineID;
+      }))
+      .rangeBands([0, width], 0.2);
+
+    var yPlacementScale = d3.scale.linear()
+      .domain([0, 1.1 * d3.max(placement, function(d) {
+        return d.Units;
+      })])
+      .range([height, 0]);
+
+    placementCanvas.append("g")
+      .attr("class", "x axis")
+      .attr("transform", "translate(0," + height + ")")
+      .call(d3.svg.axis()
+        .scale(xPlacementScale)
+        .orient("bottom")
+        .outerTickSize(0));
+
+    placementCanvas.append("g")
+      .attr("class", "y axis")
+      .call(d3.svg.axis()
+        .scale(yPlacementScale)
+        .ticks(10)
+        .orient("left")
+        .innerTickSize(-width)
+        .outerTickSize(0));
+
+    placementCanvas.selectAll("rect.placement")
+      .data(placement)
+      .enter()
+      .append("rect")
+      .attr("class", "placement")
+      .attr("x", function(d) {
+        return xPlacementScale(d.Hostname || d.MachineID);
+      })
+      .attr("width", xPlacementScale.rangeBand())
+      .attr("y", function(d) {
+        return yPlacementScale(d.Units);
+      })
+      .attr("height", function(d) {
+        return height - yPlacementScale(d.Units);
+      })
+      .on("mouseover", function(d) {
+        div.transition()
+          .duration(20)
+          .style("opacity", .9);
+        div.html(stringify(d))
+          .style("left", (d3.event.pageX) + "px")
+          .style("top", (d3.event.pageY - 28) + "px");
+      })
+      .on("mouseout", function(d) {
+        div.transition()
+          .duration(500)
+          .style("opacity", 0);
+      });
+
+    placementCanvas.append("text")
+      .attr("transform", "rotate(-90)")
+      .attr("y", 0 - margin.left)
+      .attr("x", 0 - (height / 2))
+      .attr("dy", "1em")
+      .style("text-anchor", "middle")
+      .text("instance groups");
+  }
+};
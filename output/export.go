@@ -0,0 +1,148 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/nomi/samples"
+	"github.com/giantswarm/nomi/unit"
+)
+
+// Formats of the tables written by ExportTables
+const (
+	FormatCSV = "csv"
+	FormatTSV = "tsv"
+)
+
+// Columns of the tables, the times are in seconds since the start of the
+// benchmark and the values which were not observed are left empty. Columns are
+// only ever appended, to keep the existing ones stable.
+var (
+	operationColumns = []string{"id", "start_time_s", "completion_time_s", "delay_s",
+		"starting_count", "running_count", "stopping_count", "stopped_count", "hostname", "machine_id",
+		"submitted_time_s", "scheduled_time_s", "loaded_time_s", "launched_time_s", "active_time_s"}
+	eventColumns        = []string{"command", "args", "start_time_s", "end_time_s", "control"}
+	apiCallColumns      = []string{"op", "start_time_s", "latency_s", "failed"}
+	appMetricColumns    = []string{"id", "name", "value", "timestamp_s"}
+	machineStatsColumns = []string{"host", "process", "timestamp_s", "cpu_usage_percent", "rss_kb",
+		"threads", "fds", "voluntary_ctx_switches", "nonvoluntary_ctx_switches"}
+	hostStatsColumns = []string{"host", "timestamp_s", "load1", "load5", "load15",
+		"mem_total_kb", "mem_available_kb", "systemd_units"}
+)
+
+// ExportTables writes every series of the benchmark to its own file in dir,
+// one row per record after a header, in the csv or the tsv format
+func ExportTables(stats unit.Stats, format, dir string) error {
+	if format != FormatCSV && format != FormatTSV {
+		return fmt.Errorf("unknown output format %q, expected %s or %s", format, FormatCSV, FormatTSV)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// the started, stopped and failed operations share their columns, the
+	// rows of all of them are built at once and split after
+	operations := append(stats.Start[:len(stats.Start):len(stats.Start)], stats.Stop...)
+	operations = append(operations, stats.Failed...)
+	rows := [][]string{}
+	for _, l := range operations {
+		rows = append(rows, []string{l.ID, formatFloat(l.StartTime), formatFloat(l.CompletionTime), formatFloat(l.Delay),
+			strconv.Itoa(l.StartingCount), strconv.Itoa(l.RunningCount), strconv.Itoa(l.StoppingCount), strconv.Itoa(l.StoppedCount), l.Hostname, l.MachineID,
+			formatObserved(l.SubmittedTime), formatObserved(l.ScheduledTime), formatObserved(l.LoadedTime), formatObserved(l.LaunchedTime), formatObserved(l.ActiveTime)})
+	}
+	stopped := len(stats.Start) + len(stats.Stop)
+	start, stop, failed := rows[:len(stats.Start)], rows[len(stats.Start):stopped], rows[stopped:]
+
+	events := [][]string{}
+	for _, ev := range stats.EventLog {
+		events = append(events, []string{ev.Cmd, strings.Join(ev.Args, " "), formatFloat(ev.StartTime), formatFloat(ev.EndTime), strconv.FormatBool(ev.Control)})
+	}
+	apiCalls := [][]string{}
+	for _, call := range stats.APICalls {
+		apiCalls = append(apiCalls, []string{call.Op, formatFloat(call.StartTime), formatFloat(call.Latency), strconv.FormatBool(call.Failed)})
+	}
+	appMetrics := [][]string{}
+	for _, m := range stats.AppMetrics {
+		appMetrics = append(appMetrics, []string{m.UnitID, m.Name, strconv.FormatFloat(m.Value, 'g', -1, 64), formatFloat(m.TimeStamp)})
+	}
+
+	// the machine stats come from the samples, which tell the metrics a
+	// machine did not report apart from the zeros
+	machineStats, hostStats := [][]string{}, [][]string{}
+	lines := append(stats.Samples[:0:0], stats.Samples...)
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Host < lines[j].Host })
+	for _, l := range lines {
+		metric := func(name string) string {
+			value, reported := l.Metrics[name]
+			if !reported {
+				return ""
+			}
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		}
+		if l.Process == "" {
+			hostStats = append(hostStats, []string{l.Host, formatFloat(l.TimeStamp), metric(samples.Load1), metric(samples.Load5), metric(samples.Load15),
+				metric(samples.MemTotal), metric(samples.MemAvailable), metric(samples.SystemdUnits)})
+			continue
+		}
+		machineStats = append(machineStats, []string{l.Host, l.Process, formatFloat(l.TimeStamp), metric(samples.CPUUsage), metric(samples.RSS),
+			metric(samples.Threads), metric(samples.FDs), metric(samples.VoluntaryCtxSwitches), metric(samples.NonvoluntaryCtxSwitches)})
+	}
+
+	var tables = []struct {
+		Name    string
+		Columns []string
+		Rows    [][]string
+	}{
+		{"start", operationColumns, start},
+		{"stop", operationColumns, stop},
+		{"failed", operationColumns, failed},
+		{"events", eventColumns, events},
+		{"api_calls", apiCallColumns, apiCalls},
+		{"app_metrics", appMetricColumns, appMetrics},
+		{"machine_stats", machineStatsColumns, machineStats},
+		{"host_stats", hostStatsColumns, hostStats},
+	}
+	for _, table := range tables {
+		path := filepath.Join(dir, table.Name+"."+format)
+		if err := writeTable(path, format, table.Columns, table.Rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTable(path, format string, columns []string, rows [][]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(file)
+	if format == FormatTSV {
+		w.Comma = '\t'
+	}
+	w.Write(columns)
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 6, 64)
+}
+
+// formatObserved formats the timestamps which are 0 when they were not
+// observed, leaving them empty
+func formatObserved(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return formatFloat(f)
+}
@@ -0,0 +1,68 @@
+package output
+
+import (
+	"encoding/csv"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/nomi/definition"
+	"github.com/giantswarm/nomi/unit"
+)
+
+func TestExportTables(t *testing.T) {
+	def, _ := definition.BenchmarkDefByRawInstructions("(start 1 0)", 1)
+	engine, _ := unit.NewEngine(def, false)
+	engine.SpawnFunc = func(id string) error {
+		engine.MarkUnitRunning(id, "core-1", "m1")
+		return nil
+	}
+	engine.StopFunc = func(id string) error {
+		engine.MarkUnitStopped(id, "core-1", "m1")
+		return nil
+	}
+	engine.Run()
+
+	dir, err := ioutil.TempDir("", "nomi-export")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ExportTables(engine.Stats(), FormatTSV, dir); err != nil {
+		log.Fatal(err)
+	}
+
+	file, err := os.Open(filepath.Join(dir, "start.tsv"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	r := csv.NewReader(file)
+	r.Comma = '\t'
+	records, err := r.ReadAll()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(records) != 2 || strings.Join(records[0], ",") != strings.Join(operationColumns, ",") {
+		log.Fatalf("expected a header and one row, got: %v", records)
+	}
+	if records[1][8] != "core-1" || records[1][9] != "m1" {
+		log.Fatalf("wrong machine of the start, got: %v", records[1])
+	}
+	// the unit states were not polled, only the submission is known
+	if records[1][11] != "" || records[1][14] != "" {
+		log.Fatalf("expected the unobserved phases to be empty, got: %v", records[1])
+	}
+
+	for _, name := range []string{"stop.tsv", "failed.tsv", "events.tsv", "api_calls.tsv", "app_metrics.tsv", "machine_stats.tsv", "host_stats.tsv"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			log.Fatalf("%s was not written: %v", name, err)
+		}
+	}
+	if err := ExportTables(engine.Stats(), "xlsx", dir); err == nil {
+		log.Fatalf("an unknown format was accepted")
+	}
+}
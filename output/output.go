@@ -6,10 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
-	"github.com/aybabtme/uniplot/histogram"
+	"github.com/coreos/fleet/schema"
 
 	"github.com/giantswarm/nomi/log"
 	"github.com/giantswarm/nomi/unit"
@@ -23,7 +26,7 @@ func DumpJSON(stats unit.Stats) {
 
 // DumpJSON dumps the stats metrics to a javascript file 'data.js' which should
 // be used by embedded scripts to print a graphic.
-func DumpHTMLTar(html []byte, scriptJs []byte, stats unit.Stats) {
+func DumpHTMLTar(html []byte, scriptJs []byte, styleCSS []byte, stats unit.Stats) {
 	jsonData := bytes.NewBufferString("var allData = ")
 	enc := json.NewEncoder(jsonData)
 	enc.Encode(stats)
@@ -37,6 +40,7 @@ func DumpHTMLTar(html []byte, scriptJs []byte, stats unit.Stats) {
 		{"data.js", jsonData.Bytes()},
 		{"index.html", html},
 		{"script.js", scriptJs},
+		{"style.css", styleCSS},
 	}
 
 	for _, file := range files {
@@ -61,41 +65,155 @@ func DumpHTMLTar(html []byte, scriptJs []byte, stats unit.Stats) {
 
 }
 
-// PrintReport prints in stdout a report of the the units delay for the start operation.
+// PrintReport prints to out a report of the delays of the start and stop
+// operations and of the requests to the backend API
 func PrintReport(stats unit.Stats, out io.Writer) {
-	delays := []float64{}
 	maxRunningCount := 0
-	minStartingTime := 10000000.0
-	minDelay := 10000000.0
-	maxDelay := 0.0
-	maxCompletionTime := 0.0
-
+	startDelays, startFirst, startLast := []float64{}, math.MaxFloat64, 0.0
 	for _, ev := range stats.Start {
 		if maxRunningCount <= ev.RunningCount {
 			maxRunningCount = ev.RunningCount
 		}
-		if minDelay >= ev.Delay {
-			minDelay = ev.Delay
+		startFirst = math.Min(startFirst, ev.StartTime)
+		startLast = math.Max(startLast, ev.CompletionTime)
+		startDelays = append(startDelays, ev.Delay)
+	}
+	stopDelays, stopFirst, stopLast := []float64{}, math.MaxFloat64, 0.0
+	for _, ev := range stats.Stop {
+		stopFirst = math.Min(stopFirst, ev.StartTime)
+		stopLast = math.Max(stopLast, ev.CompletionTime)
+		stopDelays = append(stopDelays, ev.Delay)
+	}
+
+	printMetadata(stats.Metadata, out)
+	fmt.Fprintln(out, "Number of running units:", maxRunningCount)
+	printSeries("Start delay", "units", startDelays, startFirst, startLast, len(stats.Failed), out)
+	printPhaseBreakdown(stats, out)
+	printPlacement(stats, out)
+	printSeries("Stop delay", "units", stopDelays, stopFirst, stopLast, stats.StopPending, out)
+	printAppMetrics(stats, out)
+	printControlActions(stats, out)
+
+	printCounts("Retried API requests", stats.APIRetries, out)
+	printCounts("Failed API requests", stats.APIFailures, out)
+	printAPILatencies(stats, out)
+}
+
+// printAPILatencies prints the latency of every type of request sent to the
+// backend API, the failed requests are counted apart
+func printAPILatencies(stats unit.Stats, out io.Writer) {
+	latencies := map[string][]float64{}
+	failures := map[string]int{}
+	first, last := map[string]float64{}, map[string]float64{}
+	for _, call := range stats.APICalls {
+		if _, seen := first[call.Op]; !seen {
+			first[call.Op] = call.StartTime
 		}
-		if maxDelay <= ev.Delay {
-			maxDelay = ev.Delay
+		first[call.Op] = math.Min(first[call.Op], call.StartTime)
+		last[call.Op] = math.Max(last[call.Op], call.StartTime+call.Latency)
+		if call.Failed {
+			failures[call.Op]++
+			continue
 		}
-		if minStartingTime > ev.StartTime {
-			minStartingTime = ev.StartTime
+		latencies[call.Op] = append(latencies[call.Op], call.Latency)
+	}
+
+	ops := []string{}
+	for op := range first {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	for _, op := range ops {
+		printSeries("API latency "+op, "calls", latencies[op], first[op], last[op], failures[op], out)
+	}
+}
+
+// printMetadata prints where the results come from
+func printMetadata(metadata unit.Metadata, out io.Writer) {
+	if metadata.Before == nil {
+		return
+	}
+	fmt.Fprintf(out, "nomi %s (build %s), %s backend\n", metadata.Version, metadata.Build, metadata.Backend)
+	fmt.Fprintf(out, "Cluster: %d machines, %d units before the benchmark", len(metadata.Before.Machines), metadata.Before.UnitCount)
+	if metadata.After != nil {
+		fmt.Fprintf(out, ", %d machines and %d units after", len(metadata.After.Machines), metadata.After.UnitCount)
+	}
+	fmt.Fprintln(out)
+	if len(metadata.Before.FleetVersions) > 0 {
+		fmt.Fprintf(out, "fleet versions: %s\n", strings.Join(metadata.Before.FleetVersions, ", "))
+	}
+	if metadata.Before.EtcdVersion != "" {
+		fmt.Fprintf(out, "etcd version: %s\n", metadata.Before.EtcdVersion)
+	}
+}
+
+// printPlacement prints how many instance groups landed on every machine
+func printPlacement(stats unit.Stats, out io.Writer) {
+	if len(stats.Placement) == 0 {
+		return
+	}
+	fmt.Fprintln(out, "-- Units per machine --")
+	for _, p := range stats.Placement {
+		machineID := p.MachineID
+		if len(machineID) > 8 {
+			machineID = machineID[:8]
 		}
-		if maxCompletionTime <= ev.CompletionTime {
-			maxCompletionTime = ev.CompletionTime
+		fmt.Fprintf(out, "%-20s %-8s %6d units  mean delay %.3fs  max delay %.3fs\n", p.Hostname, machineID, p.Units, p.MeanDelay, p.MaxDelay)
+	}
+	fmt.Fprintf(out, "Placement imbalance (busiest/mean): %.2f  stddev: %.2f units\n", stats.PlacementImbalance, stats.PlacementStddev)
+}
+
+// printControlActions prints when the benchmark was paused, resumed, aborted
+// or given more instructions through the control API
+func printControlActions(stats unit.Stats, out io.Writer) {
+	header := false
+	for _, ev := range stats.EventLog {
+		if !ev.Control {
+			continue
+		}
+		if !header {
+			fmt.Fprintln(out, "-- Control actions --")
+			header = true
 		}
+		fmt.Fprintf(out, "%10.3fs %s\n", ev.StartTime, strings.Join(append([]string{ev.Cmd}, ev.Args...), " "))
+	}
+}
 
-		delays = append(delays, ev.Delay)
+func printCounts(title string, counts map[string]int, out io.Writer) {
+	if len(counts) == 0 {
+		return
 	}
-	hist := histogram.Hist(10, delays)
+	ops := []string{}
+	for op := range counts {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	fmt.Fprintf(out, "-- %s --\n", title)
+	for _, op := range ops {
+		fmt.Fprintf(out, "%s: %d\n", op, counts[op])
+	}
+}
 
-	fmt.Println("Number of runnings units: ", maxRunningCount)
-	fmt.Println("Minimum time to start an unit: ", minDelay)
-	fmt.Println("Maximum time to start an unit: ", maxDelay)
-	fmt.Println("Time to compute the start operation (secs): ", float64(maxCompletionTime-minStartingTime))
+// PrintDryRun prints the unit files that a benchmark deploys and the timeline of
+// its instructions
+func PrintDryRun(units []schema.Unit, timeline []unit.TimelineEntry, out io.Writer) {
+	for _, u := range units {
+		fmt.Fprintf(out, "# %s\n", u.Name)
+		fmt.Fprintln(out, schema.MapSchemaUnitOptionsToUnitFile(u.Options).String())
+	}
 
-	fmt.Println("-- Histogram Starting Delay --")
-	histogram.Fprint(out, hist, histogram.Linear(20))
+	fmt.Fprintln(out, "-- Timeline --")
+	for _, entry := range timeline {
+		bound := ""
+		if entry.AfterWait {
+			bound = ">="
+		}
+		when := fmt.Sprintf("%s%.3fs", bound, entry.Start.Seconds())
+		if entry.End > entry.Start {
+			when += fmt.Sprintf(" - %s%.3fs", bound, entry.End.Seconds())
+		}
+		fmt.Fprintf(out, "%-26s %-15s %s\n", when, entry.Cmd, entry.Description)
+	}
 }
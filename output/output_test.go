@@ -0,0 +1,37 @@
+package output
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/fleet/schema"
+
+	"github.com/giantswarm/nomi/unit"
+)
+
+func TestPrintDryRun(t *testing.T) {
+	units := []schema.Unit{{
+		Name:    "nomi-0@1.service",
+		Options: []*schema.UnitOption{{Section: "Service", Name: "ExecStart", Value: "/bin/sleep 60"}},
+	}}
+	timeline := []unit.TimelineEntry{
+		{Start: 0, End: 2 * time.Second, Cmd: "start", Description: "spawns"},
+		{Start: 2 * time.Second, End: 2 * time.Second, AfterWait: true, Cmd: "end", Description: "finishes"},
+	}
+
+	out := new(bytes.Buffer)
+	PrintDryRun(units, timeline, out)
+	for _, expected := range []string{
+		"# nomi-0@1.service\n",
+		"-- Timeline --\n",
+		"0.000s - 2.000s",
+		">=2.000s ",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			log.Fatalf("expected %q in the dry run, got:\n%s", expected, out.String())
+		}
+	}
+}
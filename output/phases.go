@@ -0,0 +1,71 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/giantswarm/nomi/unit"
+)
+
+// Phases of the start operation, in the order they happen
+var startPhases = []string{"submission", "scheduling", "agent load", "systemd activation", "hello callback"}
+
+// phaseDurations splits the delay of a start operation into the time spent in
+// each phase out of the timestamps: start request, submitted, scheduled,
+// loaded, active and completion. The phases that were not observed are merged
+// into the following one. It returns false when no phase was observed.
+func phaseDurations(timestamps []float64) ([]float64, bool) {
+	start, completion := timestamps[0], timestamps[len(timestamps)-1]
+	if timestamps[1] == 0 {
+		return nil, false
+	}
+
+	durations := []float64{}
+	previous := start
+	for _, t := range timestamps[1:] {
+		if t == 0 || t < previous {
+			t = previous
+		}
+		if t > completion {
+			t = completion
+		}
+		durations = append(durations, t-previous)
+		previous = t
+	}
+	return durations, true
+}
+
+// printPhaseBreakdown prints how much of the start delay is spent on average
+// in each phase of the start operation
+func printPhaseBreakdown(stats unit.Stats, out io.Writer) {
+	totals := make([]float64, len(startPhases))
+	count := 0
+	for _, ev := range stats.Start {
+		durations, ok := phaseDurations([]float64{ev.StartTime, ev.SubmittedTime, ev.ScheduledTime, ev.LoadedTime, ev.ActiveTime, ev.CompletionTime})
+		if !ok {
+			continue
+		}
+		for i, d := range durations {
+			totals[i] += d
+		}
+		count++
+	}
+	if count == 0 {
+		return
+	}
+
+	total := 0.0
+	for _, t := range totals {
+		total += t
+	}
+
+	fmt.Fprintf(out, "-- Start phases (mean of %d units) --\n", count)
+	for i, phase := range startPhases {
+		share := 0.0
+		if total > 0 {
+			share = totals[i] / total
+		}
+		fmt.Fprintf(out, "%-18s %8.3fs %6.2f%%  %s\n", phase, totals[i]/float64(count), 100*share, strings.Repeat("█", int(20*share+0.5)))
+	}
+}
@@ -0,0 +1,30 @@
+package output
+
+import (
+	"log"
+	"testing"
+)
+
+func TestPhaseDurations(t *testing.T) {
+	// start, submitted, scheduled, loaded, active, completion
+	durations, ok := phaseDurations([]float64{1, 1.5, 3, 0, 6, 7})
+	if !ok {
+		log.Fatalf("expected the phases to be known")
+	}
+	expected := []float64{0.5, 1.5, 0, 3, 1}
+	for i := range expected {
+		if durations[i] != expected[i] {
+			log.Fatalf("phase %d: expected %v got %v", i, expected[i], durations[i])
+		}
+	}
+
+	// The active state was polled after the hello callback
+	durations, _ = phaseDurations([]float64{0, 1, 2, 3, 9, 5})
+	if durations[3] != 2 || durations[4] != 0 {
+		log.Fatalf("expected phases to be capped at completion, got %v", durations)
+	}
+
+	if _, ok := phaseDurations([]float64{0, 0, 0, 0, 0, 5}); ok {
+		log.Fatalf("expected unknown phases")
+	}
+}
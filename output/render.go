@@ -5,6 +5,7 @@ package output
 import (
 	"fmt"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/giantswarm/nomi/log"
@@ -15,11 +16,25 @@ import (
 // Directory where the plots are stored by default
 const plotsDIR = "/nomi_plots"
 
-var processTypes = []string{"fleetd", "systemd", "etcd"}
+// processesOf returns the monitored processes found in the collected metrics
+func processesOf(stats unit.Stats) []string {
+	found := map[string]bool{}
+	processes := []string{}
+	for _, metrics := range stats.MachineStats {
+		for _, metric := range metrics {
+			if !found[metric.Process] {
+				found[metric.Process] = true
+				processes = append(processes, metric.Process)
+			}
+		}
+	}
+	sort.Strings(processes)
+	return processes
+}
 
 // GeneratePlots creates some initial plots from the collected metrics. Three
 // are the initial plots: start operation completion time/delay, stop operation
-// completion time/delay and cluster metrics for systemd and fleetd.
+// completion time/delay and cluster metrics for the monitored processes.
 func GeneratePlots(stats unit.Stats, verbose bool) {
 	fname := ""
 	persist := true
@@ -61,7 +76,7 @@ func GeneratePlots(stats unit.Stats, verbose bool) {
 }
 
 func generateDelayStartPlot(fname string, persist bool, debug bool, plotsDirectory string, stats unit.Stats) {
-	for _, process := range processTypes {
+	for _, process := range processesOf(stats) {
 		p, err := gnuplot.NewPlotter(fname, persist, debug)
 		if err != nil {
 			err_string := fmt.Sprintf("** err: %v\n", err)
@@ -0,0 +1,85 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/aybabtme/uniplot/histogram"
+
+	"github.com/giantswarm/nomi/unit"
+)
+
+// Percentiles printed for every series of delays
+var percentiles = []float64{50, 90, 95, 99, 99.9}
+
+// seriesSummary describes the delays of a series of operations
+type seriesSummary struct {
+	count       int
+	mean        float64
+	stddev      float64
+	min         float64
+	max         float64
+	percentiles []float64
+
+	// span is the time between the first request and the last completion,
+	// throughput the number of operations completed per second over it
+	span       float64
+	throughput float64
+}
+
+// summarize computes the summary of delays, first and last are the time of
+// the first request and of the last completion of the operations
+func summarize(delays []float64, first, last float64) seriesSummary {
+	s := seriesSummary{count: len(delays)}
+	if len(delays) == 0 {
+		return s
+	}
+
+	sorted := append([]float64{}, delays...)
+	sort.Float64s(sorted)
+	s.min, s.max = sorted[0], sorted[len(sorted)-1]
+
+	for _, d := range sorted {
+		s.mean += d
+	}
+	s.mean /= float64(len(sorted))
+	for _, d := range sorted {
+		s.stddev += (d - s.mean) * (d - s.mean)
+	}
+	s.stddev = math.Sqrt(s.stddev / float64(len(sorted)))
+
+	for _, p := range percentiles {
+		s.percentiles = append(s.percentiles, unit.Percentile(sorted, p))
+	}
+
+	s.span = last - first
+	if s.span > 0 {
+		s.throughput = float64(len(sorted)) / s.span
+	}
+	return s
+}
+
+// printSeries prints the summary and the histogram of the delays of a series
+// of operations, counted as noun, e.g. "units" or "calls"
+func printSeries(title, noun string, delays []float64, first, last float64, failures int, out io.Writer) {
+	if len(delays) == 0 && failures == 0 {
+		return
+	}
+	fmt.Fprintf(out, "-- %s (%d %s, %d failed) --\n", title, len(delays), noun, failures)
+	if len(delays) == 0 {
+		return
+	}
+
+	s := summarize(delays, first, last)
+	fmt.Fprintf(out, "mean %.3fs  stddev %.3fs  min %.3fs  max %.3fs\n", s.mean, s.stddev, s.min, s.max)
+	ranks := []string{}
+	for i, p := range percentiles {
+		ranks = append(ranks, fmt.Sprintf("p%g %.3fs", p, s.percentiles[i]))
+	}
+	fmt.Fprintln(out, strings.Join(ranks, "  "))
+	fmt.Fprintf(out, "throughput %.2f %s/s over %.3fs\n", s.throughput, noun, s.span)
+	histogram.Fprint(out, histogram.Hist(10, delays), histogram.Linear(20))
+}
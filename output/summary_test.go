@@ -0,0 +1,45 @@
+package output
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/nomi/unit"
+)
+
+func TestSummarize(t *testing.T) {
+	delays := []float64{}
+	for i := 100; i > 0; i-- {
+		delays = append(delays, float64(i))
+	}
+	s := summarize(delays, 10, 60)
+	if s.min != 1 || s.max != 100 || s.mean != 50.5 {
+		log.Fatalf("wrong min, max or mean: %+v", s)
+	}
+	if s.stddev < 28.86 || s.stddev > 28.87 {
+		log.Fatalf("wrong stddev: %v", s.stddev)
+	}
+	expected := []float64{50.5, 90.1, 95.05, 99.01, 99.901}
+	for i, p := range expected {
+		if d := s.percentiles[i] - p; d > 1e-9 || d < -1e-9 {
+			log.Fatalf("p%v: expected %v got %v", percentiles[i], p, s.percentiles[i])
+		}
+	}
+	if s.throughput != 2 {
+		log.Fatalf("expected 2 units/s, got %v", s.throughput)
+	}
+
+	if one := summarize([]float64{3}, 0, 0); one.percentiles[4] != 3 || one.throughput != 0 {
+		log.Fatalf("wrong summary of a single delay: %+v", one)
+	}
+}
+
+func TestPrintReportOutput(t *testing.T) {
+	out := &bytes.Buffer{}
+	PrintReport(unit.Stats{StopPending: 2}, out)
+	if !strings.Contains(out.String(), "-- Stop delay (0 units, 2 failed) --") {
+		log.Fatalf("the pending stops are not reported:\n%s", out.String())
+	}
+}
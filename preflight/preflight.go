@@ -0,0 +1,187 @@
+// This preflight package checks that a benchmark can run before starting it,
+// so that failures show up in seconds instead of minutes into the run
+package preflight
+
+import (
+	"crypto/tls"
+	"debug/elf"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/nomi/backend"
+	"github.com/giantswarm/nomi/unit"
+)
+
+// Status is the outcome of a check
+type Status int
+
+const (
+	Pass Status = iota
+	Warn
+	Fail
+	Skip
+)
+
+func (s Status) String() string {
+	switch s {
+	case Pass:
+		return "OK"
+	case Warn:
+		return "WARN"
+	case Fail:
+		return "FAIL"
+	default:
+		return "SKIP"
+	}
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Name   string
+	Status Status
+	Detail string
+}
+
+// Report collects the outcome of all the checks
+type Report []Result
+
+// Go tells whether the benchmark can run, that is no check failed
+func (r Report) Go() bool {
+	for _, result := range r {
+		if result.Status == Fail {
+			return false
+		}
+	}
+	return true
+}
+
+// Print writes a go/no-go summary of the checks
+func (r Report) Print(out io.Writer) {
+	fmt.Fprintln(out, "-- Pre-flight checks --")
+	for _, result := range r {
+		fmt.Fprintf(out, "[%4s] %s: %s\n", result.Status, result.Name, result.Detail)
+	}
+	if r.Go() {
+		fmt.Fprintln(out, "GO")
+	} else {
+		fmt.Fprintln(out, "NO-GO")
+	}
+}
+
+// Config describes the benchmark to check
+type Config struct {
+	Backend    backend.Backend
+	Builder    *unit.Builder
+	ListenAddr string
+	// AppType is the type of the benchmark application, docker and rkt
+	// require the runtime on every machine
+	AppType string
+	// Plots tells whether gnuplot is required
+	Plots bool
+	// AgentBinary is the nomi binary the agent units download, checked to
+	// run on the cluster machines. It is left empty when the backend does
+	// not run the agents on the machines of a cluster.
+	AgentBinary string
+	// Probe launches a global unit calling back to ListenAddr from every
+	// machine. Backends which do not run commands cannot be probed.
+	Probe        bool
+	ProbeTimeout time.Duration
+	// Certificate is served to the probe unit when the units reach nomi
+	// over TLS
+	Certificate *tls.Certificate
+}
+
+// Run performs all the checks. The backend and the probe checks need the
+// listen address to be free.
+func Run(config Config) Report {
+	report := Report{}
+
+	result, reachable := checkBackend(config)
+	report = append(report, result)
+	if reachable {
+		report = append(report, CheckLeftovers(config))
+	}
+	report = append(report, checkGnuplot(config))
+	report = append(report, CheckAgentBinary(config))
+
+	if !config.Probe || !reachable {
+		report = append(report, Result{"probe", Skip, "the backend does not run the probe unit"})
+		return report
+	}
+	return append(report, probe(config)...)
+}
+
+func checkBackend(config Config) (Result, bool) {
+	units, err := config.Backend.ListUnits()
+	if err != nil {
+		return Result{"backend", Fail, fmt.Sprintf("unable to reach the backend: %v", err)}, false
+	}
+	return Result{"backend", Pass, fmt.Sprintf("reachable, %d units in the cluster", len(units))}, true
+}
+
+// CheckLeftovers looks for the units of this run, or of other nomi runs, left
+// in the cluster. Only the Backend and the Builder of the config are used.
+func CheckLeftovers(config Config) Result {
+	units, err := config.Backend.ListUnits()
+	if err != nil {
+		return Result{"leftover units", Fail, err.Error()}
+	}
+
+	prefix := config.Builder.GetUnitPrefix()
+	leftovers := []string{}
+	otherRuns := map[string]int{}
+	for _, u := range units {
+		if strings.HasPrefix(u.Name, prefix+"-") {
+			leftovers = append(leftovers, u.Name)
+		} else if runPrefix, ok := unit.RunPrefixOf(u); ok {
+			otherRuns[runPrefix]++
+		}
+	}
+
+	switch {
+	case len(leftovers) > 0:
+		return Result{"leftover units", Fail, fmt.Sprintf("%d units of this run already exist, e.g. %s; remove them with nomi cleanup --prefix %s-", len(leftovers), leftovers[0], prefix)}
+	case len(otherRuns) > 0:
+		runs := []string{}
+		for runPrefix, count := range otherRuns {
+			runs = append(runs, fmt.Sprintf("%s (%d units)", runPrefix, count))
+		}
+		sort.Strings(runs)
+		return Result{"leftover units", Warn, fmt.Sprintf("units of other nomi runs exist: %s; if they are left over from crashed runs, remove them with nomi cleanup", strings.Join(runs, ", "))}
+	default:
+		return Result{"leftover units", Pass, fmt.Sprintf("no units with prefix %q", prefix)}
+	}
+}
+
+func checkGnuplot(config Config) Result {
+	_, err := exec.LookPath("gnuplot")
+	switch {
+	case err == nil:
+		return Result{"gnuplot", Pass, "found"}
+	case config.Plots:
+		return Result{"gnuplot", Fail, "required by --generate-gnuplots but not found"}
+	default:
+		return Result{"gnuplot", Warn, "not found, plots cannot be generated"}
+	}
+}
+
+// CheckAgentBinary checks that the agent binary is built for linux/amd64, the
+// platform of the CoreOS machines. Only the AgentBinary of the config is used.
+func CheckAgentBinary(config Config) Result {
+	if config.AgentBinary == "" {
+		return Result{"agent binary", Skip, "the backend does not run the agents on cluster machines"}
+	}
+	file, err := elf.Open(config.AgentBinary)
+	if err != nil {
+		return Result{"agent binary", Fail, fmt.Sprintf("%s is not a linux binary (%v), build nomi for linux/amd64 and pass it with --agent-binary", config.AgentBinary, err)}
+	}
+	defer file.Close()
+	if file.Machine != elf.EM_X86_64 || (file.OSABI != elf.ELFOSABI_NONE && file.OSABI != elf.ELFOSABI_LINUX) {
+		return Result{"agent binary", Fail, fmt.Sprintf("%s is built for %s %s, build nomi for linux/amd64 and pass it with --agent-binary", config.AgentBinary, file.OSABI, file.Machine)}
+	}
+	return Result{"agent binary", Pass, fmt.Sprintf("%s, linux/amd64", config.AgentBinary)}
+}
@@ -0,0 +1,65 @@
+package preflight
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestEvaluateProbe(t *testing.T) {
+	config := Config{ListenAddr: "10.0.0.1:40302", AppType: "docker"}
+	reports := map[string]probeReport{
+		"m1": {Hostname: "host-1", Docker: "1.10.3"},
+		"m2": {Hostname: "host-2", Rkt: "rkt Version: 1.4.0"},
+	}
+
+	report := evaluateProbe(config, []string{"m1", "m2", "m3"}, reports)
+	if report.Go() {
+		log.Fatalf("expected a no-go, got %v", report)
+	}
+	if report[0].Status != Fail || report[1].Status != Fail || report[2].Status != Warn {
+		log.Fatalf("unexpected results: %v", report)
+	}
+
+	config.AppType = ""
+	report = evaluateProbe(config, []string{"m1", "m2"}, reports)
+	if !report.Go() {
+		log.Fatalf("expected a go, got %v", report)
+	}
+
+	report = evaluateProbe(config, nil, map[string]probeReport{})
+	if report.Go() {
+		log.Fatalf("expected a no-go when no machine calls back")
+	}
+}
+
+func TestCheckAgentBinary(t *testing.T) {
+	if result := CheckAgentBinary(Config{}); result.Status != Skip {
+		log.Fatalf("expected the check to be skipped without agents, got %v", result)
+	}
+
+	script, err := ioutil.TempFile("", "nomi-agent")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove(script.Name())
+	script.WriteString("#!/bin/sh\n")
+	script.Close()
+	if result := CheckAgentBinary(Config{AgentBinary: script.Name()}); result.Status != Fail {
+		log.Fatalf("expected a script to fail the check, got %v", result)
+	}
+
+	test, err := os.Executable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	expected := Fail
+	if runtime.GOOS == "linux" && runtime.GOARCH == "amd64" {
+		expected = Pass
+	}
+	if result := CheckAgentBinary(Config{AgentBinary: test}); result.Status != expected {
+		log.Fatalf("expected %v for a %s/%s binary, got %v", expected, runtime.GOOS, runtime.GOARCH, result)
+	}
+}
@@ -0,0 +1,149 @@
+package preflight
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/giantswarm/nomi/backend"
+	"github.com/giantswarm/nomi/unit"
+)
+
+const probePollInterval = 500 * time.Millisecond
+
+// probeReport is what the probe unit tells about a machine
+type probeReport struct {
+	Hostname string
+	Docker   string
+	Rkt      string
+}
+
+// probe launches the global probe unit and waits for every machine to call
+// back with the versions of its container runtimes
+func probe(config Config) Report {
+	listener, err := net.Listen("tcp", config.ListenAddr)
+	if err != nil {
+		return Report{{"callback address", Fail, fmt.Sprintf("unable to listen on %s: %v", config.ListenAddr, err)}}
+	}
+	if config.Certificate != nil {
+		listener = tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{*config.Certificate}})
+	}
+	defer listener.Close()
+	config.ListenAddr = unit.CallbackAddr(config.ListenAddr, listener.Addr())
+	config.Builder.UseListenAddr(config.ListenAddr)
+
+	mu := new(sync.Mutex)
+	reports := map[string]probeReport{}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/probe/{machineID}", func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		mu.Lock()
+		reports[mux.Vars(req)["machineID"]] = probeReport{
+			Hostname: query.Get("host"),
+			Docker:   query.Get("docker"),
+			Rkt:      query.Get("rkt"),
+		}
+		mu.Unlock()
+		w.Write([]byte("ok.\n"))
+	}).Methods("GET")
+	go http.Serve(listener, r)
+
+	var expected []string
+	if lister, ok := config.Backend.(backend.MachineLister); ok {
+		machines, err := lister.Machines()
+		if err == nil {
+			for _, m := range machines {
+				expected = append(expected, m.ID)
+			}
+		}
+	}
+
+	probeUnit := config.Builder.MakeProbeUnit()
+	if err := config.Backend.StartUnit(probeUnit); err != nil {
+		return Report{{"probe", Fail, fmt.Sprintf("unable to start the probe unit: %v", err)}}
+	}
+	defer config.Backend.Destroy(probeUnit.Name)
+
+	deadline := time.Now().Add(config.ProbeTimeout)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		received := len(reports)
+		mu.Unlock()
+		if expected != nil && received >= len(expected) {
+			break
+		}
+		time.Sleep(probePollInterval)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return evaluateProbe(config, expected, reports)
+}
+
+// evaluateProbe checks that the expected machines called back and that they
+// run the container runtime of the benchmark application. When the machines
+// are unknown, any machine calling back is enough.
+func evaluateProbe(config Config, expected []string, reports map[string]probeReport) Report {
+	report := Report{}
+
+	if len(reports) == 0 {
+		return append(report, Result{"callback address", Fail, fmt.Sprintf("no machine called back to %s within %s, check that --addr is reachable from the cluster", config.ListenAddr, config.ProbeTimeout)})
+	}
+
+	missing := []string{}
+	for _, id := range expected {
+		if _, exists := reports[id]; !exists {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		report = append(report, Result{"callback address", Fail, fmt.Sprintf("%d of %d machines did not call back to %s: %s", len(missing), len(expected), config.ListenAddr, strings.Join(missing, ", "))})
+	} else {
+		report = append(report, Result{"callback address", Pass, fmt.Sprintf("%d machines called back to %s", len(reports), config.ListenAddr)})
+	}
+
+	report = append(report, checkRuntime("docker", config.AppType == "docker", reports, func(p probeReport) string { return p.Docker }))
+	report = append(report, checkRuntime("rkt", config.AppType == "rkt", reports, func(p probeReport) string { return p.Rkt }))
+	return report
+}
+
+func checkRuntime(name string, required bool, reports map[string]probeReport, version func(probeReport) string) Result {
+	versions := map[string]int{}
+	absent := []string{}
+	for id, p := range reports {
+		if v := version(p); v != "" {
+			versions[v]++
+		} else if p.Hostname != "" {
+			absent = append(absent, p.Hostname)
+		} else {
+			absent = append(absent, id)
+		}
+	}
+	sort.Strings(absent)
+
+	found := []string{}
+	for v, count := range versions {
+		found = append(found, fmt.Sprintf("%s on %d machines", v, count))
+	}
+	sort.Strings(found)
+
+	switch {
+	case len(absent) == 0:
+		return Result{name, Pass, strings.Join(found, ", ")}
+	case required:
+		return Result{name, Fail, fmt.Sprintf("required by the application but missing on %s", strings.Join(absent, ", "))}
+	case len(found) == 0:
+		return Result{name, Skip, "not installed"}
+	default:
+		return Result{name, Warn, fmt.Sprintf("missing on %s", strings.Join(absent, ", "))}
+	}
+}
@@ -0,0 +1,59 @@
+// Package samples defines the payload the machines push their metrics to the
+// nomi observer with, on /v1/stats/{source}
+package samples
+
+import (
+	"fmt"
+	"time"
+)
+
+// PathPrefix is the observer endpoint the batches are posted to, followed by
+// the name of the source
+const PathPrefix = "/v1/stats/"
+
+// Names of the metrics nomi knows about. Sources are free to send any other
+// metric, they are stored as they are.
+const (
+	// of a process
+	CPUUsage                = "cpu_usage"
+	RSS                     = "rss"
+	Threads                 = "threads"
+	FDs                     = "fds"
+	VoluntaryCtxSwitches    = "voluntary_ctx_switches"
+	NonvoluntaryCtxSwitches = "nonvoluntary_ctx_switches"
+	Processes               = "processes"
+
+	// of a host
+	Load1        = "load1"
+	Load5        = "load5"
+	Load15       = "load15"
+	MemTotal     = "mem_total"
+	MemAvailable = "mem_available"
+	SystemdUnits = "systemd_units"
+)
+
+// Sample is a set of metrics of a host, or of one of its processes, taken at
+// Timestamp by the sender
+type Sample struct {
+	Host      string             `json:"host"`
+	Process   string             `json:"process,omitempty"`
+	Timestamp time.Time          `json:"timestamp"`
+	Metrics   map[string]float64 `json:"metrics"`
+}
+
+type Batch struct {
+	Samples []Sample `json:"samples"`
+}
+
+// Validate checks that every sample tells where and when it was taken
+func (b Batch) Validate() error {
+	for i, sample := range b.Samples {
+		if sample.Host == "" {
+			return fmt.Errorf("sample %d has no host", i)
+		}
+		if sample.Timestamp.IsZero() {
+			return fmt.Errorf("sample %d has no timestamp", i)
+		}
+	}
+	return nil
+}
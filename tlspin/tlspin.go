@@ -0,0 +1,115 @@
+// Package tlspin generates the self-signed certificate the nomi observer
+// serves, and checks the public key its clients pin instead of a CA
+package tlspin
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"time"
+)
+
+// validity is how long the generated certificates are valid, longer than any
+// benchmark
+const validity = 30 * 24 * time.Hour
+
+// NewSelfSigned generates a certificate for host along with its pin in the
+// format of curl --pinnedpubkey
+func NewSelfSigned(host string) (tls.Certificate, string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, "", err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, "", err
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "nomi observer"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else if host != "" {
+		template.DNSNames = []string{host}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, "", err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, "", err
+	}
+	pin, err := Of(cert)
+	if err != nil {
+		return tls.Certificate{}, "", err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, pin, nil
+}
+
+// Of returns the pin of the public key of a certificate: sha256// followed by
+// the base64 encoded SHA-256 digest of its DER encoded SubjectPublicKeyInfo
+func Of(cert *x509.Certificate) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(der)
+	return "sha256//" + base64.StdEncoding.EncodeToString(digest[:]), nil
+}
+
+// Client returns an HTTP client which only talks to servers whose certificate
+// has the pinned public key
+func Client(pin string, timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialTLS: func(network, addr string) (net.Conn, error) {
+				// the certificate is self-signed, the pin replaces the
+				// verification of the chain
+				conn, err := tls.DialWithDialer(dialer, network, addr, &tls.Config{InsecureSkipVerify: true})
+				if err != nil {
+					return nil, err
+				}
+				if err := check(conn.ConnectionState(), pin); err != nil {
+					conn.Close()
+					return nil, err
+				}
+				return conn, nil
+			},
+		},
+	}
+}
+
+func check(state tls.ConnectionState, pin string) error {
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("the server sent no certificate")
+	}
+	actual, err := Of(state.PeerCertificates[0])
+	if err != nil {
+		return err
+	}
+	if actual != pin {
+		return fmt.Errorf("the public key of the server %s does not match the pinned %s", actual, pin)
+	}
+	return nil
+}
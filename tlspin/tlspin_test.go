@@ -0,0 +1,34 @@
+package tlspin
+
+import (
+	"crypto/tls"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient(t *testing.T) {
+	cert, pin, err := NewSelfSigned("127.0.0.1")
+	if err != nil {
+		log.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok.\n"))
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	defer server.Close()
+
+	resp, err := Client(pin, 5*time.Second).Get(server.URL)
+	if err != nil {
+		log.Fatalf("the pinned server was rejected: %v", err)
+	}
+	resp.Body.Close()
+
+	_, other, _ := NewSelfSigned("127.0.0.1")
+	if _, err := Client(other, 5*time.Second).Get(server.URL); err == nil {
+		log.Fatalf("a server with another public key was accepted")
+	}
+}
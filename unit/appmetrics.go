@@ -0,0 +1,142 @@
+package unit
+
+import (
+	"math"
+	"sort"
+
+	"github.com/giantswarm/nomi/definition"
+)
+
+// appMetricLine is a measurement reported by a benchmark unit
+type appMetricLine struct {
+	UnitID    string
+	Name      string
+	Value     float64
+	TimeStamp float64
+}
+
+// metricSummary aggregates the measurements of a metric, of an unit or of the
+// whole application. Units is the number of units that reported it.
+type metricSummary struct {
+	Count int
+	Units int
+	Sum   float64
+	Mean  float64
+	Min   float64
+	Max   float64
+	Last  float64
+}
+
+func (s metricSummary) aggregate(name string) float64 {
+	switch name {
+	case definition.AggregateCount:
+		return float64(s.Count)
+	case definition.AggregateSum:
+		return s.Sum
+	case definition.AggregateMean:
+		return s.Mean
+	case definition.AggregateMin:
+		return s.Min
+	}
+	return s.Max
+}
+
+func (s *metricSummary) add(value float64) {
+	if s.Count == 0 {
+		s.Min, s.Max = value, value
+	}
+	s.Count++
+	s.Sum += value
+	s.Mean = s.Sum / float64(s.Count)
+	s.Min = math.Min(s.Min, value)
+	s.Max = math.Max(s.Max, value)
+	s.Last = value
+}
+
+type assertionResult struct {
+	definition.Assertion
+	Actual   float64
+	Measured bool
+	Passed   bool
+}
+
+// DumpAppMetrics collects the measurements an unit reports about the
+// application it runs
+func (e *UnitEngine) DumpAppMetrics(unitID string, metrics map[string]float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	names := []string{}
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	timestamp := e.secondsSinceStart(e.clock.Now())
+	for _, name := range names {
+		e.appMetrics = append(e.appMetrics, appMetricLine{
+			UnitID:    unitID,
+			Name:      name,
+			Value:     metrics[name],
+			TimeStamp: timestamp,
+		})
+	}
+}
+
+// summarizeAppMetrics aggregates the measurements per unit and metric, and per
+// metric over all the units
+func summarizeAppMetrics(lines []appMetricLine) (map[string]map[string]metricSummary, map[string]metricSummary) {
+	byUnit := map[string]map[string]metricSummary{}
+	total := map[string]metricSummary{}
+	for _, line := range lines {
+		if _, exists := byUnit[line.UnitID]; !exists {
+			byUnit[line.UnitID] = map[string]metricSummary{}
+		}
+		unitSummary := byUnit[line.UnitID][line.Name]
+		if unitSummary.Count == 0 {
+			unitSummary.Units = 1
+		}
+		unitSummary.add(line.Value)
+		byUnit[line.UnitID][line.Name] = unitSummary
+
+		totalSummary := total[line.Name]
+		totalSummary.add(line.Value)
+		total[line.Name] = totalSummary
+	}
+
+	for _, metrics := range byUnit {
+		for name := range metrics {
+			totalSummary := total[name]
+			totalSummary.Units++
+			total[name] = totalSummary
+		}
+	}
+	return byUnit, total
+}
+
+// checkAssertions evaluates the assertions of the benchmark against the
+// metrics of the application. An assertion on a metric no unit reported fails.
+func checkAssertions(assertions []definition.Assertion, total map[string]metricSummary) []assertionResult {
+	results := []assertionResult{}
+	for _, assertion := range assertions {
+		result := assertionResult{Assertion: assertion}
+		if summary, measured := total[assertion.Metric]; measured {
+			result.Measured = true
+			result.Actual = summary.aggregate(assertion.Aggregate)
+			result.Passed = assertion.Check(result.Actual)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// FailedAssertions returns the number of assertions that did not hold
+func (s Stats) FailedAssertions() int {
+	failed := 0
+	for _, result := range s.Assertions {
+		if !result.Passed {
+			failed++
+		}
+	}
+	return failed
+}
@@ -0,0 +1,44 @@
+package unit
+
+import (
+	"log"
+	"testing"
+
+	"github.com/giantswarm/nomi/definition"
+)
+
+func TestAppMetrics(t *testing.T) {
+	def, _ := definition.BenchmarkDefByRawInstructions("(start 1 0)", 1)
+	def.Assertions = []definition.Assertion{
+		{Metric: "first_request", Aggregate: definition.AggregateMean, Symbol: definition.Lower, Value: 2},
+		{Metric: "errors", Aggregate: definition.AggregateSum, Symbol: definition.Lower, Value: 3},
+		{Metric: "missing", Aggregate: definition.AggregateCount, Symbol: definition.Greater, Value: 0},
+	}
+	engine, _ := NewEngine(def, false)
+
+	engine.DumpAppMetrics("a", map[string]float64{"first_request": 1, "errors": 1})
+	engine.DumpAppMetrics("a", map[string]float64{"errors": 2})
+	engine.DumpAppMetrics("b", map[string]float64{"first_request": 2})
+
+	stats := engine.Stats()
+	if len(stats.AppMetrics) != 4 {
+		log.Fatalf("expected 4 measurements, got: %+v", stats.AppMetrics)
+	}
+
+	errors := stats.AppMetricsByUnit["a"]["errors"]
+	if errors.Count != 2 || errors.Sum != 3 || errors.Min != 1 || errors.Max != 2 || errors.Last != 2 || errors.Units != 1 {
+		log.Fatalf("wrong summary of unit a, got: %+v", errors)
+	}
+	firstRequest := stats.AppMetricsTotal["first_request"]
+	if firstRequest.Count != 2 || firstRequest.Units != 2 || firstRequest.Mean != 1.5 || firstRequest.Max != 2 {
+		log.Fatalf("wrong summary of the application, got: %+v", firstRequest)
+	}
+
+	results := stats.Assertions
+	if len(results) != 3 || !results[0].Passed || results[0].Actual != 1.5 || results[1].Passed || results[2].Passed || results[2].Measured {
+		log.Fatalf("wrong assertion results, got: %+v", results)
+	}
+	if stats.FailedAssertions() != 2 {
+		log.Fatalf("expected 2 failed assertions, got: %d", stats.FailedAssertions())
+	}
+}
@@ -4,6 +4,7 @@ package unit
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
 	"path/filepath"
 	"strings"
 
@@ -17,15 +18,32 @@ import (
 const (
 	nomiUnitPrefix = "nomi"
 
+	// nomiSection marks the units generated by nomi with the prefix of the
+	// run they belong to
+	nomiSection = "X-Nomi"
+
 	rktTestImage = "docker://giantswarm/alpine-curl"
+
+	metricsURLEnv          = "NOMI_METRICS_URL"
+	metricsPinnedPubKeyEnv = "NOMI_METRICS_PINNEDPUBKEY"
+
+	// CallbackTokenParam is the query parameter carrying the callback token
+	// in the requests of the units to the observer
+	CallbackTokenParam = "token"
 )
 
 type Builder struct {
 	unitPrefix        string
+	runID             string
 	listenAddr        string
 	app               definition.Application
 	instanceGroupSize int
 	unitFile          *unit.UnitFile
+
+	// callbackToken authenticates the requests of the units to the observer,
+	// which are sent over https pinning pinnedPubKey when it is set
+	callbackToken string
+	pinnedPubKey  string
 }
 
 func NewBuilder(app definition.Application, instanceGroupSize int, listenAddr string) (*Builder, error) {
@@ -41,7 +59,34 @@ func NewBuilder(app definition.Application, instanceGroupSize int, listenAddr st
 	}, nil
 }
 
-func (b *Builder) GetUnitPrefix() string {
+// UseRunID scopes the names of the units to a run, <app>-<run>, so that
+// several benchmarks of the same application can share a cluster
+func (b *Builder) UseRunID(runID string) {
+	b.runID = runID
+	b.unitPrefix = b.GetAppPrefix() + "-" + runID
+}
+
+// UseListenAddr changes the address the units call back to, once the observer
+// listens on it
+func (b *Builder) UseListenAddr(listenAddr string) {
+	b.listenAddr = listenAddr
+}
+
+// UseCallbackToken adds a token shared by the units of the run to all their
+// requests to the observer
+func (b *Builder) UseCallbackToken(token string) {
+	b.callbackToken = token
+}
+
+// UseTLS makes the units reach the observer over https. Its certificate is
+// self-signed, the units only trust the pinned public key.
+func (b *Builder) UseTLS(pinnedPubKey string) {
+	b.pinnedPubKey = pinnedPubKey
+}
+
+// GetAppPrefix returns the prefix of the units of the application, shared by
+// all its runs
+func (b *Builder) GetAppPrefix() string {
 	prefix := nomiUnitPrefix
 	if b.app.Name != "" {
 		prefix = b.app.Name
@@ -49,29 +94,199 @@ func (b *Builder) GetUnitPrefix() string {
 	return prefix
 }
 
-// MakeStatsDumper creates nomi specific units to collect metrics in each host
-func (b *Builder) MakeStatsDumper(name, cmd, statsEndpoint string) schema.Unit {
-	prefix := nomiUnitPrefix
-	if b.app.Name != "" {
-		prefix = b.app.Name
+// GetUnitPrefix returns the prefix of the units of the run
+func (b *Builder) GetUnitPrefix() string {
+	return b.unitPrefix
+}
+
+// nomiMarker tells which run an unit belongs to
+func (b *Builder) nomiMarker() *schema.UnitOption {
+	return &schema.UnitOption{
+		Section: nomiSection,
+		Name:    "Prefix",
+		Value:   b.unitPrefix,
+	}
+}
+
+// RunPrefixOf returns the prefix of the run which generated an unit, when the
+// unit was generated by nomi
+func RunPrefixOf(u *schema.Unit) (string, bool) {
+	for _, option := range u.Options {
+		if option.Section == nomiSection && option.Name == "Prefix" {
+			return option.Value, true
+		}
 	}
+	return "", false
+}
+
+// MakeStatsDumper creates a global unit running the nomi agent in each host.
+// The agent binary is downloaded from the observer.
+func (b *Builder) MakeStatsDumper(monitor definition.Monitor) schema.Unit {
+	binary := b.agentBinary()
 	return schema.Unit{
-		Name: prefix + "-stats-dumper-" + name + ".service",
+		Name: b.unitPrefix + "-agent.service",
 		Options: []*schema.UnitOption{
+			{
+				Section: "Service",
+				Name:    "ExecStartPre",
+				Value:   "/bin/sh -c '" + b.curl() + " -f -o " + binary + " " + b.callbackURL(AgentBinaryPath, "") + " && chmod +x " + binary + "'",
+			},
 			{
 				Section: "Service",
 				Name:    "ExecStart",
-				Value:   "/bin/bash -c 'while : ; do " + cmd + "| curl -s -X POST -d @- http://" + b.listenAddr + "/stats/" + statsEndpoint + " ; done'",
+				Value: fmt.Sprintf("%s agent --addr %s --monitor %s --monitor-interval %d --hostname %%H%s",
+					binary, b.listenAddr, definition.FormatMonitorProcesses(monitor.Processes), monitor.Interval, b.agentSecurityFlags()),
+			},
+			{
+				Section: "Service",
+				Name:    "ExecStopPost",
+				Value:   "/bin/rm -f " + binary,
 			},
 			{
 				Section: "X-Fleet",
 				Name:    "Global",
 				Value:   "true",
 			},
+			b.nomiMarker(),
+		},
+	}
+}
+
+// agentBinary returns where the agent unit downloads the nomi binary to on
+// every machine
+func (b *Builder) agentBinary() string {
+	return "/tmp/" + b.unitPrefix + "-agent"
+}
+
+// MakeProbeUnit creates a global unit that reports the hostname and the
+// versions of docker and rkt of every machine to /probe/%m
+func (b *Builder) MakeProbeUnit() schema.Unit {
+	return schema.Unit{
+		Name: b.unitPrefix + "-preflight-probe.service",
+		Options: []*schema.UnitOption{
+			{
+				Section: "Service",
+				Name:    "Type",
+				Value:   "oneshot",
+			},
+			{
+				Section: "Service",
+				Name:    "ExecStart",
+				Value: "/bin/sh -c '" + b.curl() + " -G" +
+					" --data-urlencode \"host=%H\"" +
+					" --data-urlencode \"docker=$$(docker version --format \"{{.Server.Version}}\" 2>/dev/null)\"" +
+					" --data-urlencode \"rkt=$$(rkt version 2>/dev/null | head -n1)\"" +
+					" " + b.callbackURL("/probe/%m", "") + "'",
+			},
+			{
+				Section: "X-Fleet",
+				Name:    "Global",
+				Value:   "true",
+			},
+			b.nomiMarker(),
+		},
+	}
+}
+
+// curl returns the curl command the units reach the observer with
+func (b *Builder) curl() string {
+	if b.pinnedPubKey != "" {
+		return "/usr/bin/curl -s -k --pinnedpubkey " + b.pinnedPubKey
+	}
+	return "/usr/bin/curl -s"
+}
+
+// callbackURL returns the URL of an observer endpoint, with the callback token
+// appended to the query
+func (b *Builder) callbackURL(path, query string) string {
+	scheme := "http"
+	if b.pinnedPubKey != "" {
+		scheme = "https"
+	}
+	return scheme + "://" + b.listenAddr + b.callbackPath(path, query)
+}
+
+// callbackPath returns the path and the query of a request to the observer,
+// with the callback token appended to the query
+func (b *Builder) callbackPath(path, query string) string {
+	if b.callbackToken != "" {
+		if query != "" {
+			query += "&"
+		}
+		query += CallbackTokenParam + "=" + b.callbackToken
+	}
+	if query != "" {
+		path += "?" + query
+	}
+	return path
+}
+
+// agentSecurityFlags returns the flags passing the callback token and the
+// pinned public key to the agent
+func (b *Builder) agentSecurityFlags() string {
+	flags := ""
+	if b.callbackToken != "" {
+		flags += " --token " + b.callbackToken
+	}
+	if b.pinnedPubKey != "" {
+		flags += " --pinned-pubkey " + b.pinnedPubKey
+	}
+	return flags
+}
+
+// notifyCmd returns the command the benchmark units run to report an event of
+// their instance group, together with the machine they run on, using the
+// notifier of the application
+func (b *Builder) notifyCmd(event string) string {
+	switch b.app.Notifier {
+	case definition.NotifierBash:
+		// a plain HTTP/1.0 request, the observer closes the connection once
+		// it answered
+		host, port, _ := net.SplitHostPort(b.listenAddr)
+		request := "GET " + b.callbackPath("/"+event+"/%i", "host=%H&machine=%m") + " HTTP/1.0\\nHost: " + b.listenAddr + "\\n\\n"
+		return "/bin/bash -c 'exec 3<>/dev/tcp/" + host + "/" + port + " && printf \"" + request + "\" >&3 && cat <&3 >/dev/null'"
+	case definition.NotifierNomi:
+		notify := b.agentBinary() + " notify --addr " + b.listenAddr + b.agentSecurityFlags() + " --host %H --machine %m " + event + " %i"
+		if event == "hello" {
+			// the binary is downloaded by the agent unit of the machine
+			return "/bin/sh -c 'until [ -x " + b.agentBinary() + " ]; do sleep 1; done; exec " + notify + "'"
+		}
+		return notify
+	}
+	return b.curl() + " \"" + b.callbackURL("/"+event+"/%i", "host=%H&machine=%m") + "\""
+}
+
+// notifyOptions returns the option reporting an event of the instance group,
+// none when the states of the units are inferred from the backend
+func (b *Builder) notifyOptions(name, event string) []*schema.UnitOption {
+	if b.app.Notifier == definition.NotifierState {
+		return nil
+	}
+	return []*schema.UnitOption{
+		{
+			Section: "Service",
+			Name:    name,
+			Value:   b.notifyCmd(event),
 		},
 	}
 }
 
+// metricsURL returns the URL the benchmark units report the metrics of the
+// application to, available to them in $NOMI_METRICS_URL
+func (b *Builder) metricsURL() string {
+	return b.callbackURL("/metrics/%i", "")
+}
+
+// metricsEnvironment returns the variables telling the application where to
+// report its metrics: the URL, and the public key to pin over https
+func (b *Builder) metricsEnvironment() []string {
+	env := []string{metricsURLEnv + "=" + b.metricsURL()}
+	if b.pinnedPubKey != "" {
+		env = append(env, metricsPinnedPubKeyEnv+"="+b.pinnedPubKey)
+	}
+	return env
+}
+
 // MakeUnitChain creates the unit files of the benchmark units.
 func (b *Builder) MakeUnitChain(id string) []schema.Unit {
 	unitsList := []schema.Unit{}
@@ -94,6 +309,15 @@ func (b *Builder) MakeUnitChain(id string) []schema.Unit {
 			unit.Options = b.buildShellService()
 		}
 
+		for _, env := range b.metricsEnvironment() {
+			unit.Options = append(unit.Options, &schema.UnitOption{
+				Section: "Service",
+				Name:    "Environment",
+				Value:   env,
+			})
+		}
+		unit.Options = append(unit.Options, b.nomiMarker())
+
 		if i > 0 {
 			depName := fmt.Sprintf("%s-%d@%s.service", b.unitPrefix, i-1, id)
 			unit.Options = append(unit.Options,
@@ -162,6 +386,9 @@ func (b *Builder) generateDockerRunCmd() string {
 	for key, value := range b.app.Envs {
 		envs = envs + fmt.Sprintf(" -e %s=%s", key, value)
 	}
+	for _, env := range b.metricsEnvironment() {
+		envs = envs + " -e " + env
+	}
 	if b.app.Network != "" {
 		net = " --net=" + b.app.Network
 	}
@@ -185,32 +412,26 @@ func (b *Builder) buildDockerService() []*schema.UnitOption {
 			Name:    "ExecStartPre",
 			Value:   "-/bin/bash -c '/usr/bin/docker rm -f %p-%i'",
 		},
-		{
-			Section: "Service",
-			Name:    "ExecStartPre",
-			Value:   "/bin/sh -c '/usr/bin/curl -s http://" + b.listenAddr + "/hello/%i'",
-		},
-		{
+	}
+	unit = append(unit, b.notifyOptions("ExecStartPre", "hello")...)
+	unit = append(unit,
+		&schema.UnitOption{
 			Section: "Service",
 			Name:    "ExecStart",
 			Value:   dockerExec,
 		},
-		{
+		&schema.UnitOption{
 			Section: "Service",
 			Name:    "ExecStop",
 			Value:   "-/bin/bash -c '/usr/bin/docker kill %p-%i'",
 		},
-		{
-			Section: "Service",
-			Name:    "ExecStopPost",
-			Value:   "/usr/bin/curl -s http://" + b.listenAddr + "/bye/%i",
-		},
-		{
-			Section: "Service",
-			Name:    "ExecStopPost",
-			Value:   "-/bin/bash -c '/usr/bin/docker rm -f %p-%i'",
-		},
-	}
+	)
+	unit = append(unit, b.notifyOptions("ExecStopPost", "bye")...)
+	unit = append(unit, &schema.UnitOption{
+		Section: "Service",
+		Name:    "ExecStopPost",
+		Value:   "-/bin/bash -c '/usr/bin/docker rm -f %p-%i'",
+	})
 
 	return unit
 }
@@ -235,6 +456,9 @@ func (b *Builder) generateRktRunCmd() string {
 	for key, value := range b.app.Envs {
 		envs = envs + fmt.Sprintf(" --set-env=%s=%s", key, value)
 	}
+	for _, env := range b.metricsEnvironment() {
+		envs = envs + " --set-env=" + env
+	}
 	if len(b.app.Args[:]) > 0 {
 		args = "--exec=" + strings.Join(b.app.Args[:], " -- ")
 	}
@@ -256,81 +480,56 @@ func (b *Builder) buildRktService() []*schema.UnitOption {
 			Name:    "ExecStartPre",
 			Value:   "/usr/bin/mkdir -p /run/rkt-uuids",
 		},
-		{
-			Section: "Service",
-			Name:    "ExecStartPre",
-			Value:   "/bin/sh -c '/usr/bin/curl -s http://" + b.listenAddr + "/hello/%i'",
-		},
-		{
+	}
+	unit = append(unit, b.notifyOptions("ExecStartPre", "hello")...)
+	unit = append(unit,
+		&schema.UnitOption{
 			Section: "Service",
 			Name:    "ExecStart",
 			Value:   rktExec,
 		},
-		{
+		&schema.UnitOption{
 			Section: "Service",
 			Name:    "KillMode",
 			Value:   "mixed",
 		},
-		{
-			Section: "Service",
-			Name:    "ExecStop",
-			Value:   "/usr/bin/curl -s http://" + b.listenAddr + "/bye/%i",
-		},
-		{
-			Section: "Service",
-			Name:    "ExecStopPost",
-			Value:   "/usr/bin/rkt rm --uuid-file=/run/rkt-uuids/%p-%i",
-		},
-	}
+	)
+	unit = append(unit, b.notifyOptions("ExecStop", "bye")...)
+	unit = append(unit, &schema.UnitOption{
+		Section: "Service",
+		Name:    "ExecStopPost",
+		Value:   "/usr/bin/rkt rm --uuid-file=/run/rkt-uuids/%p-%i",
+	})
 
 	return unit
 }
 
 func (b *Builder) buildShellService() []*schema.UnitOption {
-	return []*schema.UnitOption{
-		{
-			Section: "Service",
-			Name:    "ExecStartPre",
-			Value:   "/bin/sh -c '/usr/bin/curl -s http://" + b.listenAddr + "/hello/%i'",
-		},
-		{
+	unit := b.notifyOptions("ExecStartPre", "hello")
+	unit = append(unit,
+		&schema.UnitOption{
 			Section: "Service",
 			Name:    "ExecStart",
 			Value:   "/bin/sh -c 'sleep 90000'",
 		},
-		{
+		&schema.UnitOption{
 			Section: "Service",
 			Name:    "TimeoutStopSec",
 			Value:   "1s",
 		},
-		{
+		&schema.UnitOption{
 			Section: "Service",
 			Name:    "KillSignal",
 			Value:   "SIGKILL",
 		},
-		{
-			Section: "Service",
-			Name:    "ExecStopPost",
-			Value:   "/usr/bin/curl -s http://" + b.listenAddr + "/bye/%i",
-		},
-	}
+	)
+	return append(unit, b.notifyOptions("ExecStopPost", "bye")...)
 }
 
 func (b *Builder) buildCustomService() []*schema.UnitOption {
 	unitOptions := schema.MapUnitFileToSchemaUnitOptions(b.unitFile)
 
-	nomiNotifiers := []*schema.UnitOption{
-		{
-			Section: "Service",
-			Name:    "ExecStartPre",
-			Value:   "/bin/sh -c '/usr/bin/curl -s http://" + b.listenAddr + "/hello/%i'",
-		},
-		{
-			Section: "Service",
-			Name:    "ExecStopPost",
-			Value:   "/usr/bin/curl -s http://" + b.listenAddr + "/bye/%i",
-		},
-	}
+	nomiNotifiers := append(b.notifyOptions("ExecStartPre", "hello"), b.notifyOptions("ExecStopPost", "bye")...)
 
 	unitOptions = append(unitOptions, nomiNotifiers...)
 
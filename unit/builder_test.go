@@ -2,6 +2,7 @@ package unit
 
 import (
 	"log"
+	"strings"
 	"testing"
 
 	"github.com/giantswarm/nomi/definition"
@@ -47,3 +48,116 @@ func TestBuilder(t *testing.T) {
 		log.Fatalf("wrong options name and value expected 'KillMode' 'Mixed' got: %s %s", options3[3].Name, options3[3].Value)
 	}
 }
+
+func TestBuilderRunID(t *testing.T) {
+	builder, err := NewBuilder(definition.Application{Name: "web"}, 2, "127.0.0.1:54541")
+	if err != nil {
+		log.Fatal(err)
+	}
+	builder.UseRunID("ab12")
+
+	units := builder.MakeUnitChain("1")
+	if units[0].Name != "web-ab12-1@1.service" || units[1].Name != "web-ab12-0@1.service" {
+		log.Fatalf("wrong unit names, got: %s %s", units[0].Name, units[1].Name)
+	}
+	if builder.GetAppPrefix() != "web" || builder.GetUnitPrefix() != "web-ab12" {
+		log.Fatalf("wrong prefixes, got: %s %s", builder.GetAppPrefix(), builder.GetUnitPrefix())
+	}
+
+	for _, u := range append(units, builder.MakeStatsDumper(definition.DefaultMonitor()), builder.MakeProbeUnit()) {
+		prefix, ok := RunPrefixOf(&u)
+		if !ok || prefix != "web-ab12" {
+			log.Fatalf("unit %s is not marked with its run, got %q", u.Name, prefix)
+		}
+	}
+}
+
+func TestStatsDumper(t *testing.T) {
+	builder, _ := NewBuilder(definition.Application{}, 1, "127.0.0.1:54541")
+	builder.UseRunID("abcd")
+
+	monitor := definition.Monitor{
+		Interval:  5,
+		Processes: []definition.MonitoredProcess{{Name: "fleetd"}, {Name: "systemd", PID: 1}},
+	}
+	dumper := builder.MakeStatsDumper(monitor)
+	if dumper.Name != "nomi-abcd-agent.service" {
+		log.Fatalf("wrong agent unit name, got: %s", dumper.Name)
+	}
+
+	expected := "/tmp/nomi-abcd-agent agent --addr 127.0.0.1:54541 --monitor fleetd,systemd=1 --monitor-interval 5 --hostname %H"
+	if dumper.Options[1].Name != "ExecStart" || dumper.Options[1].Value != expected {
+		log.Fatalf("wrong agent command, got: %s", dumper.Options[1].Value)
+	}
+	if !strings.Contains(dumper.Options[0].Value, "http://127.0.0.1:54541/agent/binary") {
+		log.Fatalf("the agent binary is not downloaded from the observer, got: %s", dumper.Options[0].Value)
+	}
+}
+
+func TestMetricsURL(t *testing.T) {
+	app := definition.Application{Type: "docker", Image: "nginx"}
+	builder, _ := NewBuilder(app, 1, "127.0.0.1:54541")
+
+	expected := "NOMI_METRICS_URL=http://127.0.0.1:54541/metrics/%i"
+	environment, dockerRun := "", ""
+	for _, option := range builder.MakeUnitChain("1")[0].Options {
+		switch option.Name {
+		case "Environment":
+			environment = option.Value
+		case "ExecStart":
+			dockerRun = option.Value
+		}
+	}
+	if environment != expected || !strings.Contains(dockerRun, " -e "+expected+" ") {
+		log.Fatalf("the metrics URL is not passed to the application, got: %q %q", environment, dockerRun)
+	}
+}
+
+func TestCallbackSecurity(t *testing.T) {
+	builder, _ := NewBuilder(definition.Application{}, 1, "127.0.0.1:54541")
+	builder.UseCallbackToken("s3cret")
+	builder.UseTLS("sha256//abc=")
+
+	expected := `/usr/bin/curl -s -k --pinnedpubkey sha256//abc= "https://127.0.0.1:54541/hello/%i?host=%H&machine=%m&token=s3cret"`
+	if cmd := builder.notifyCmd("hello"); cmd != expected {
+		log.Fatalf("wrong notify command, got: %s", cmd)
+	}
+
+	environment := []string{}
+	for _, option := range builder.MakeUnitChain("1")[0].Options {
+		if option.Name == "Environment" {
+			environment = append(environment, option.Value)
+		}
+	}
+	if strings.Join(environment, " ") != "NOMI_METRICS_URL=https://127.0.0.1:54541/metrics/%i?token=s3cret NOMI_METRICS_PINNEDPUBKEY=sha256//abc=" {
+		log.Fatalf("wrong metrics environment, got: %v", environment)
+	}
+
+	agent := builder.MakeStatsDumper(definition.DefaultMonitor())
+	if !strings.HasSuffix(agent.Options[1].Value, " --token s3cret --pinned-pubkey sha256//abc=") {
+		log.Fatalf("the agent does not get the token and the pinned key, got: %s", agent.Options[1].Value)
+	}
+}
+
+func TestNotifiers(t *testing.T) {
+	notifyCmd := func(notifier string) string {
+		builder, _ := NewBuilder(definition.Application{Notifier: notifier}, 1, "10.0.0.5:40302")
+		builder.UseRunID("ab12")
+		for _, option := range builder.MakeUnitChain("1")[0].Options {
+			if option.Name == "ExecStopPost" {
+				return option.Value
+			}
+		}
+		return ""
+	}
+
+	if cmd := notifyCmd(definition.NotifierBash); !strings.HasPrefix(cmd, "/bin/bash -c 'exec 3<>/dev/tcp/10.0.0.5/40302 && printf \"GET /bye/%i?host=%H&machine=%m HTTP/1.0") {
+		log.Fatalf("wrong bash notifier, got: %s", cmd)
+	}
+	if cmd := notifyCmd(definition.NotifierNomi); cmd != "/tmp/nomi-ab12-agent notify --addr 10.0.0.5:40302 --host %H --machine %m bye %i" {
+		log.Fatalf("wrong nomi notifier, got: %s", cmd)
+	}
+	if cmd := notifyCmd(definition.NotifierState); cmd != "" {
+		log.Fatalf("the units call back with the state notifier, got: %s", cmd)
+	}
+}
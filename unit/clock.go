@@ -0,0 +1,46 @@
+package unit
+
+import "time"
+
+// Clock abstracts the passing of time in the engine, so a benchmark can run on
+// a virtual clock when its units are simulated
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+type scaledClock struct {
+	origin time.Time
+	factor float64
+}
+
+// NewScaledClock returns a virtual clock which runs factor times faster than
+// the wall clock
+func NewScaledClock(factor float64) Clock {
+	if factor <= 0 {
+		factor = 1
+	}
+	return &scaledClock{
+		origin: time.Now(),
+		factor: factor,
+	}
+}
+
+func (c *scaledClock) Now() time.Time {
+	elapsed := time.Since(c.origin)
+	return c.origin.Add(time.Duration(float64(elapsed) * c.factor))
+}
+
+func (c *scaledClock) Sleep(d time.Duration) {
+	time.Sleep(time.Duration(float64(d) / c.factor))
+}
@@ -0,0 +1,175 @@
+package unit
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/nomi/definition"
+)
+
+// controlPollInterval is how often a paused benchmark checks whether it was
+// resumed, and the longest a sleep runs before noticing an abort
+const controlPollInterval = 1 * time.Second
+
+var (
+	errPaused    = errors.New("the benchmark is already paused")
+	errNotPaused = errors.New("the benchmark is not paused")
+	errAborted   = errors.New("the benchmark was aborted")
+	errFinished  = errors.New("the benchmark already finished")
+)
+
+// Pause holds the instructions and the starts of new units until Resume is
+// called. The instructions in progress, like a sleep, keep running.
+func (e *UnitEngine) Pause() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.checkControllable(); err != nil {
+		return err
+	}
+	if e.paused {
+		return errPaused
+	}
+	e.paused = true
+	e.logControl("pause", nil)
+	return nil
+}
+
+// Resume continues a paused benchmark
+func (e *UnitEngine) Resume() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.checkControllable(); err != nil {
+		return err
+	}
+	if !e.paused {
+		return errNotPaused
+	}
+	e.paused = false
+	e.logControl("resume", nil)
+	return nil
+}
+
+// Abort skips the remaining and the queued instructions, the benchmark then
+// stops all its units and reports the metrics collected so far
+func (e *UnitEngine) Abort() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.checkControllable(); err != nil {
+		return err
+	}
+	e.aborted = true
+	e.paused = false
+	e.queued = nil
+	e.logControl("abort", nil)
+	return nil
+}
+
+// QueueInstructions parses raw instructions and runs them while the benchmark
+// is in progress, in order, alongside the instructions of its definition
+func (e *UnitEngine) QueueInstructions(raw string) error {
+	def, err := definition.BenchmarkDefByRawInstructions(raw, e.benchmark.InstanceGroupSize)
+	if err != nil {
+		return err
+	}
+	if len(def.Instructions) == 0 {
+		return errors.New("no instructions to queue")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.checkControllable(); err != nil {
+		return err
+	}
+	e.queued = append(e.queued, def.Instructions...)
+	e.logControl("inject", []string{raw})
+	return nil
+}
+
+// checkControllable returns why the benchmark cannot be controlled anymore,
+// the caller holds the lock
+func (e *UnitEngine) checkControllable() error {
+	if e.aborted {
+		return errAborted
+	}
+	if e.queueClosed {
+		return errFinished
+	}
+	return nil
+}
+
+// logControl records a control action in the event log, the caller holds the
+// lock
+func (e *UnitEngine) logControl(cmd string, args []string) {
+	at := e.secondsSinceStart(e.clock.Now())
+	ev := event{
+		Cmd:       cmd,
+		Args:      args,
+		StartTime: at,
+		EndTime:   at,
+		Control:   true,
+	}
+	e.eventLog = append(e.eventLog, ev)
+	e.publish(LiveEvent{Type: "event", Data: ev})
+}
+
+// runQueued runs the queued instructions until the instructions of the
+// definition are done and the queue is empty, or the benchmark is aborted
+func (e *UnitEngine) runQueued(starts *sync.WaitGroup) {
+	for {
+		e.mu.Lock()
+		if e.aborted || (e.instructionsDone && len(e.queued) == 0) {
+			e.queueClosed = true
+			e.mu.Unlock()
+			return
+		}
+		ready := len(e.queued) > 0 && !e.paused
+		var next definition.Instruction
+		if ready {
+			next = e.queued[0]
+			e.queued = e.queued[1:]
+		}
+		e.mu.Unlock()
+
+		if !ready {
+			e.clock.Sleep(controlPollInterval)
+			continue
+		}
+		e.runInstruction(next, starts)
+	}
+}
+
+// waitWhilePaused blocks while the benchmark is paused, it returns false when
+// the benchmark was aborted
+func (e *UnitEngine) waitWhilePaused() bool {
+	for {
+		e.mu.Lock()
+		paused, aborted := e.paused, e.aborted
+		e.mu.Unlock()
+		if aborted {
+			return false
+		}
+		if !paused {
+			return true
+		}
+		e.clock.Sleep(controlPollInterval)
+	}
+}
+
+func (e *UnitEngine) isAborted() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.aborted
+}
+
+// sleep waits for d unless the benchmark is aborted meanwhile
+func (e *UnitEngine) sleep(d time.Duration) {
+	for d > 0 && !e.isAborted() {
+		step := controlPollInterval
+		if d < step {
+			step = d
+		}
+		e.clock.Sleep(step)
+		d -= step
+	}
+}
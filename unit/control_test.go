@@ -0,0 +1,78 @@
+package unit
+
+import (
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/nomi/definition"
+)
+
+func TestControl(t *testing.T) {
+	def, _ := definition.BenchmarkDefByRawInstructions("(start 2 0) (sleep 3600)", 1)
+	engine, _ := NewEngine(def, false)
+	engine.UseClock(NewScaledClock(1000))
+	spawned := make(chan string, 10)
+	engine.SpawnFunc = func(id string) error {
+		spawned <- id
+		return nil
+	}
+	engine.StopFunc = func(id string) error { return nil }
+
+	if err := engine.Pause(); err != nil {
+		log.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		engine.Run()
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if len(spawned) != 0 {
+		log.Fatalf("units were started while the benchmark was paused")
+	}
+	if err := engine.QueueInstructions("(restart-all)"); err == nil {
+		log.Fatalf("unknown instructions were queued")
+	}
+	if err := engine.QueueInstructions("(start 1 0)"); err != nil {
+		log.Fatal(err)
+	}
+	if err := engine.Resume(); err != nil {
+		log.Fatal(err)
+	}
+	if err := engine.Resume(); err != errNotPaused {
+		log.Fatalf("expected the benchmark not to be paused, got: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-spawned:
+		case <-time.After(5 * time.Second):
+			log.Fatalf("only %d of 3 units were started", i)
+		}
+	}
+
+	if err := engine.Abort(); err != nil {
+		log.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		log.Fatalf("the benchmark did not stop once aborted")
+	}
+	if err := engine.Pause(); err != errAborted {
+		log.Fatalf("expected the benchmark to be aborted, got: %v", err)
+	}
+
+	actions := []string{}
+	for _, ev := range engine.Stats().EventLog {
+		if ev.Control {
+			actions = append(actions, ev.Cmd)
+		}
+	}
+	if strings.Join(actions, " ") != "pause inject resume abort" {
+		log.Fatalf("wrong control actions in the event log, got: %v", actions)
+	}
+}
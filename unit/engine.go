@@ -20,6 +20,9 @@ type event struct {
 	Args      []string
 	StartTime float64
 	EndTime   float64
+
+	// Set for the actions requested through the control API
+	Control bool
 }
 
 type UnitEngine struct {
@@ -37,10 +40,36 @@ type UnitEngine struct {
 
 	startedStats stats
 	stoppedStats stats
+	failedStats  stats
+
+	apiRetries  map[string]int
+	apiFailures map[string]int
+	apiCalls    []apiCallLine
+
+	phases map[string]*unitPhases
+
+	metadata Metadata
+
+	// Index of the instruction being run, -1 before the benchmark starts and
+	// the number of instructions after it ends
+	currentInstruction int
+
+	subscribers map[chan LiveEvent]bool
+
+	// State of the control API: the instructions queued while the benchmark
+	// runs, and whether it is paused or aborted
+	queued           []definition.Instruction
+	queueClosed      bool
+	instructionsDone bool
+	paused           bool
+	aborted          bool
 
-	machineStats map[string][]processStatsLine
+	samples []metricSample
+
+	appMetrics []appMetricLine
 
 	startTime time.Time
+	clock     Clock
 
 	mu *sync.Mutex
 }
@@ -50,6 +79,19 @@ type processStatsLine struct {
 	TimeStamp float64
 	CPUUsage  float64
 	RSS       int
+
+	// Only reported by the nomi agent
+	Threads                 int
+	FDs                     int
+	VoluntaryCtxSwitches    int64
+	NonvoluntaryCtxSwitches int64
+}
+
+type apiCallLine struct {
+	Op        string
+	StartTime float64
+	Latency   float64
+	Failed    bool
 }
 
 type statsLine struct {
@@ -61,34 +103,42 @@ type statsLine struct {
 	RunningCount   int
 	StoppingCount  int
 	StoppedCount   int
+	Hostname       string
+	MachineID      string
+
+	// Timestamps of the phases of the start operation, zero when unknown
+	SubmittedTime float64
+	ScheduledTime float64
+	LoadedTime    float64
+	LaunchedTime  float64
+	ActiveTime    float64
 }
 
 var Verbose bool
 
-func (l statsLine) toSSV() string {
-	return fmt.Sprintf("%s %f %f %d %d %d %d",
-		l.ID,
-		l.StartTime,
-		l.Delay,
-		l.StartingCount,
-		l.RunningCount,
-		l.StoppingCount,
-		l.StoppedCount)
-}
-
 func NewEngine(def definition.BenchmarkDef, verbose bool) (*UnitEngine, error) {
 	Verbose = verbose
 	return &UnitEngine{
-		mu:            new(sync.Mutex),
-		benchmark:     def,
-		startingUnits: map[string]UnitState{},
-		runningUnits:  map[string]UnitState{},
-		stoppingUnits: map[string]UnitState{},
-		stoppedUnits:  map[string]UnitState{},
-		startedStats:  stats{},
-		stoppedStats:  stats{},
-		eventLog:      []event{},
-		machineStats:  map[string][]processStatsLine{},
+		mu:                 new(sync.Mutex),
+		clock:              realClock{},
+		benchmark:          def,
+		startingUnits:      map[string]UnitState{},
+		runningUnits:       map[string]UnitState{},
+		stoppingUnits:      map[string]UnitState{},
+		stoppedUnits:       map[string]UnitState{},
+		startedStats:       stats{},
+		stoppedStats:       stats{},
+		failedStats:        stats{},
+		apiRetries:         map[string]int{},
+		apiFailures:        map[string]int{},
+		apiCalls:           []apiCallLine{},
+		phases:             map[string]*unitPhases{},
+		eventLog:           []event{},
+		samples:            []metricSample{},
+		appMetrics:         []appMetricLine{},
+		currentInstruction: -1,
+		subscribers:        map[chan LiveEvent]bool{},
+		queued:             []definition.Instruction{},
 	}, nil
 }
 
@@ -98,135 +148,280 @@ func (e *UnitEngine) InstanceGroupSize() int {
 	return e.benchmark.InstanceGroupSize
 }
 
-// Run computes the benchmark definition in the order specified by the user
+// UseClock replaces the wall clock used to run the benchmark and to measure the
+// delays of the units
+func (e *UnitEngine) UseClock(clock Clock) {
+	e.clock = clock
+}
+
+// Run computes the benchmark definition in the order specified by the user,
+// along with the instructions queued through the control API
 func (e *UnitEngine) Run() {
 	defer e.stopAll()
+	e.mu.Lock()
+	e.startTime = e.clock.Now()
+	e.mu.Unlock()
+
+	starts := new(sync.WaitGroup)
+	injected := make(chan struct{})
+	go func() {
+		e.runQueued(starts)
+		close(injected)
+	}()
+
+	for index, instruction := range e.benchmark.Instructions {
+		if !e.waitWhilePaused() {
+			break
+		}
+		e.enterInstruction(index)
+		e.runInstruction(instruction, starts)
+	}
+	e.mu.Lock()
+	e.instructionsDone = true
+	e.mu.Unlock()
+	<-injected
+
+	// the starts still spawning, or interrupted by an abort, are waited for
+	// so that the units they spawn are stopped too
+	starts.Wait()
+	e.enterInstruction(len(e.benchmark.Instructions))
+}
+
+// runInstruction runs a single instruction, start instructions are run in the
+// background and added to starts
+func (e *UnitEngine) runInstruction(instruction definition.Instruction, starts *sync.WaitGroup) {
 	var (
 		emptyStart         definition.Start
 		emptyFloat         definition.Float
 		emptyExpectRunning definition.ExpectRunning
 	)
-	e.startTime = time.Now()
-
-	for _, instruction := range e.benchmark.Instructions {
-		if instruction.Start != emptyStart {
-			go func(obj definition.Start) {
-				startTime := time.Now()
-				e.start(obj)
-				e.logCommand("start", []string{fmt.Sprintf("%d", instruction.Start.Max), fmt.Sprintf("%d", instruction.Start.Interval)}, startTime, time.Now())
-			}(instruction.Start)
-		}
-		if instruction.Float != emptyFloat {
-			startTime := time.Now()
-			e.float(instruction.Float)
-			e.logCommand("float", []string{fmt.Sprintf("%d", instruction.Float.Rate), fmt.Sprintf("%v", instruction.Float.Duration)}, startTime, time.Now())
-		}
-		if instruction.Sleep != 0 {
-			startTime := time.Now()
-			time.Sleep(time.Duration(instruction.Sleep) * time.Second)
-			e.logCommand("sleep", []string{fmt.Sprintf("%d", instruction.Sleep)}, startTime, time.Now())
-		}
-		if instruction.ExpectRunning != emptyExpectRunning {
-			startTime := time.Now()
-			e.expectRunning(instruction.ExpectRunning)
-			e.logCommand("expect-running", []string{fmt.Sprintf("%s", instruction.ExpectRunning.Symbol), fmt.Sprintf("%d", instruction.ExpectRunning.Amount)}, startTime, time.Now())
-		}
-		if instruction.Stop != "" {
-			startTime := time.Now()
-			e.stopAll()
-			e.logCommand("stop-all", []string{fmt.Sprintf("%s", instruction.Stop)}, startTime, time.Now())
-		}
+	if instruction.Start != emptyStart {
+		starts.Add(1)
+		go func(obj definition.Start) {
+			defer starts.Done()
+			startTime := e.clock.Now()
+			e.start(obj)
+			e.logCommand("start", []string{fmt.Sprintf("%d", obj.Max), fmt.Sprintf("%d", obj.Interval)}, startTime, e.clock.Now())
+		}(instruction.Start)
+	}
+	if instruction.Float != emptyFloat {
+		startTime := e.clock.Now()
+		e.float(instruction.Float)
+		e.logCommand("float", []string{fmt.Sprintf("%d", instruction.Float.Rate), fmt.Sprintf("%v", instruction.Float.Duration)}, startTime, e.clock.Now())
+	}
+	if instruction.Sleep != 0 {
+		startTime := e.clock.Now()
+		e.sleep(time.Duration(instruction.Sleep) * time.Second)
+		e.logCommand("sleep", []string{fmt.Sprintf("%d", instruction.Sleep)}, startTime, e.clock.Now())
+	}
+	if instruction.ExpectRunning != emptyExpectRunning {
+		startTime := e.clock.Now()
+		e.expectRunning(instruction.ExpectRunning)
+		e.logCommand("expect-running", []string{fmt.Sprintf("%s", instruction.ExpectRunning.Symbol), fmt.Sprintf("%d", instruction.ExpectRunning.Amount)}, startTime, e.clock.Now())
+	}
+	if instruction.Stop != "" {
+		startTime := e.clock.Now()
+		e.stopAll()
+		e.logCommand("stop-all", []string{fmt.Sprintf("%s", instruction.Stop)}, startTime, e.clock.Now())
 	}
 }
 
 // MarkUnitRunning collects the timestamps of the start operation for an unit
-func (e *UnitEngine) MarkUnitRunning(id string) time.Duration {
+// and the machine it was scheduled on
+func (e *UnitEngine) MarkUnitRunning(id, hostname, machineID string) time.Duration {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	return e.markUnitRunningLocked(id, hostname, machineID, e.clock.Now())
+}
+
+func (e *UnitEngine) markUnitRunningLocked(id, hostname, machineID string, now time.Time) time.Duration {
 	state, exists := e.startingUnits[id]
 	if !exists {
 		log.Logger().Errorf("unit %s cannot be found in the starting pool\n", id)
 		return time.Duration(0)
 	}
 	delete(e.startingUnits, id)
-	state.actualStartTime = time.Now()
+	state.actualStartTime = now
+	state.hostname, state.machineID = hostname, machineID
 	e.runningUnits[id] = state
-	e.startedStats = append(e.startedStats, e.genStatsLine(id, state.actualStartTime.Sub(state.startRequestTime)))
+	line := e.genPlacedStatsLine(id, state, state.actualStartTime.Sub(state.startRequestTime))
+	e.startedStats = append(e.startedStats, line)
+	e.publish(LiveEvent{Type: "start", Data: line})
 	return state.actualStartTime.Sub(state.startRequestTime)
 }
 
-// MarkUnitStopped collects the timestamps of the stop operation for an unit
-func (e *UnitEngine) MarkUnitStopped(id string) {
+// MarkUnitStopped collects the timestamps of the stop operation for an unit.
+// The machine is only taken into account when unknown at start.
+func (e *UnitEngine) MarkUnitStopped(id, hostname, machineID string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	e.markUnitStoppedLocked(id, hostname, machineID, e.clock.Now())
+}
+
+func (e *UnitEngine) markUnitStoppedLocked(id, hostname, machineID string, now time.Time) {
 	state, exists := e.stoppingUnits[id]
 	if !exists {
 		log.Logger().Errorf("unit %s cannot be found in the stopping pool\n", id)
 		return
 	}
 	delete(e.stoppingUnits, id)
-	state.actualStopTime = time.Now()
+	state.actualStopTime = now
+	if state.hostname == "" && state.machineID == "" {
+		state.hostname, state.machineID = hostname, machineID
+	}
 	e.stoppedUnits[id] = state
-	e.stoppedStats = append(e.stoppedStats, e.genStatsLine(id, state.actualStopTime.Sub(state.stopRequestTime)))
+	line := e.genPlacedStatsLine(id, state, state.actualStopTime.Sub(state.stopRequestTime))
+	e.stoppedStats = append(e.stoppedStats, line)
+	e.publish(LiveEvent{Type: "stop", Data: line})
+}
+
+// markUnitFailed moves an unit which could not be started out of the starting
+// pool
+func (e *UnitEngine) markUnitFailed(id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	state, exists := e.startingUnits[id]
+	if !exists {
+		return
+	}
+	delete(e.startingUnits, id)
+	line := e.genStatsLine(id, e.clock.Now().Sub(state.startRequestTime))
+	e.failedStats = append(e.failedStats, line)
+	e.publish(LiveEvent{Type: "failed", Data: line})
+}
+
+// RecordAPIRetry counts the requests to the backend API that were retried
+func (e *UnitEngine) RecordAPIRetry(op string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.apiRetries[op]++
+}
+
+// RecordAPIFailure counts the requests to the backend API that failed for good
+func (e *UnitEngine) RecordAPIFailure(op string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.apiFailures[op]++
+}
+
+// RecordAPICall collects the latency of a request sent to the backend API
+func (e *UnitEngine) RecordAPICall(op string, latency time.Duration, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	startTime := 0.0
+	if !e.startTime.IsZero() {
+		startTime = e.clock.Now().Add(-latency).Sub(e.startTime).Seconds()
+	}
+	e.apiCalls = append(e.apiCalls, apiCallLine{
+		Op:        op,
+		StartTime: startTime,
+		Latency:   latency.Seconds(),
+		Failed:    err != nil,
+	})
 }
 
 type Stats struct {
+	Metadata     Metadata
 	Start        stats
 	Stop         stats
+	Failed       stats
 	Script       string
 	EventLog     []event
 	MachineStats map[string][]processStatsLine
+	HostStats    map[string][]hostStatsLine
+	Samples      []metricLine
+	APIRetries   map[string]int
+	APIFailures  map[string]int
+	APICalls     []apiCallLine
+
+	// Measurements reported by the benchmark units, their aggregates per unit
+	// and over all the units, and the assertions checked against the latter
+	AppMetrics       []appMetricLine
+	AppMetricsByUnit map[string]map[string]metricSummary
+	AppMetricsTotal  map[string]metricSummary
+	Assertions       []assertionResult
+
+	// Placement of the started instance groups per machine
+	Placement          []machinePlacement
+	PlacementImbalance float64
+	PlacementStddev    float64
+
+	// Instance groups asked to stop which did not report stopped, failures
+	// once the benchmark is over
+	StopPending int
 }
 
 // Stats returns all the collected metrics
 func (e *UnitEngine) Stats() Stats {
-	return Stats{
-		Start:        e.startedStats,
-		Stop:         e.stoppedStats,
-		EventLog:     e.eventLog,
-		MachineStats: e.machineStats,
-	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.statsLocked()
 }
 
-// DumpProcessStats dumps the machine stats for the process systemd and fleetd
-func (e *UnitEngine) DumpProcessStats(statsid, hostname string, cpuusage float64, rss int) {
-	statsLine := processStatsLine{
-		Process:   statsid,
-		CPUUsage:  cpuusage,
-		RSS:       rss,
-		TimeStamp: time.Now().Sub(e.startTime).Seconds(),
+func (e *UnitEngine) statsLocked() Stats {
+	placement, imbalance, stddev := placementOf(e.startedStats, e.metadata.machines())
+	lines, machineStats, hostStats := e.metricLines()
+	byUnit, total := summarizeAppMetrics(e.appMetrics)
+	return Stats{
+		Metadata:           e.metadata,
+		Start:              e.withPhases(e.startedStats),
+		Stop:               e.stoppedStats,
+		Failed:             e.failedStats,
+		StopPending:        len(e.stoppingUnits),
+		APIRetries:         copyCounts(e.apiRetries),
+		APIFailures:        copyCounts(e.apiFailures),
+		APICalls:           e.apiCalls,
+		EventLog:           e.eventLog,
+		MachineStats:       machineStats,
+		HostStats:          hostStats,
+		Samples:            lines,
+		AppMetrics:         e.appMetrics,
+		AppMetricsByUnit:   byUnit,
+		AppMetricsTotal:    total,
+		Assertions:         checkAssertions(e.benchmark.Assertions, total),
+		Placement:          placement,
+		PlacementImbalance: imbalance,
+		PlacementStddev:    stddev,
 	}
+}
 
-	if _, exists := e.machineStats[hostname]; exists {
-		e.machineStats[hostname] = append(e.machineStats[hostname], statsLine)
-	} else {
-		e.machineStats[hostname] = []processStatsLine{statsLine}
+func copyCounts(counts map[string]int) map[string]int {
+	copied := make(map[string]int, len(counts))
+	for key, count := range counts {
+		copied[key] = count
 	}
+	return copied
 }
 
 func (e *UnitEngine) logCommand(cmd string, args []string, start time.Time, end time.Time) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	e.eventLog = append(e.eventLog,
-		event{
-			Cmd:       cmd,
-			Args:      args,
-			StartTime: start.Sub(e.startTime).Seconds(),
-			EndTime:   end.Sub(e.startTime).Seconds(),
-		})
+	ev := event{
+		Cmd:       cmd,
+		Args:      args,
+		StartTime: start.Sub(e.startTime).Seconds(),
+		EndTime:   end.Sub(e.startTime).Seconds(),
+	}
+	e.eventLog = append(e.eventLog, ev)
+	e.publish(LiveEvent{Type: "event", Data: ev})
 }
 
 func (e *UnitEngine) expectRunning(obj definition.ExpectRunning) {
 	for {
+		e.mu.Lock()
 		running := len(e.runningUnits)
+		e.mu.Unlock()
 		if obj.Symbol == ">" && running > obj.Amount {
 			return
 		}
 		if obj.Symbol == "<" && running < obj.Amount {
 			return
 		}
-		time.Sleep(1 * time.Second)
+		if e.isAborted() {
+			return
+		}
+		e.clock.Sleep(1 * time.Second)
 	}
 }
 
@@ -252,18 +447,32 @@ func genRandomID() string {
 func (e *UnitEngine) start(obj definition.Start) {
 	spawnUnit := func() {
 		newID := genRandomID()
-		e.startingUnits[newID] = UnitState{startRequestTime: time.Now()}
-		e.SpawnFunc(newID)
+		e.mu.Lock()
+		e.startingUnits[newID] = UnitState{startRequestTime: e.clock.Now()}
+		phases := &unitPhases{}
+		e.phases[newID] = phases
+		e.mu.Unlock()
+		if err := e.SpawnFunc(newID); err != nil {
+			log.Logger().Warningf("unable to start unit %s: %v", newID, err)
+			e.markUnitFailed(newID)
+			return
+		}
+		e.mu.Lock()
+		phases.submitted = e.clock.Now()
+		e.mu.Unlock()
 	}
 
 	wg := new(sync.WaitGroup)
 	for spawned := 0; spawned < obj.Max; spawned++ {
+		if !e.waitWhilePaused() {
+			break
+		}
 		wg.Add(1)
 		go func() {
 			spawnUnit()
 			wg.Done()
 		}()
-		time.Sleep(time.Duration(obj.Interval) * time.Millisecond)
+		e.sleep(time.Duration(obj.Interval) * time.Millisecond)
 	}
 	wg.Wait()
 }
@@ -271,7 +480,7 @@ func (e *UnitEngine) start(obj definition.Start) {
 func (e *UnitEngine) stopUnit(id string, state UnitState) {
 	e.mu.Lock()
 	newState := state
-	newState.stopRequestTime = time.Now()
+	newState.stopRequestTime = e.clock.Now()
 
 	if Verbose {
 		log.Logger().Infof("marking unit as to be deleted: %s", id)
@@ -285,34 +494,31 @@ func (e *UnitEngine) stopUnit(id string, state UnitState) {
 }
 
 func (e *UnitEngine) stopAll() {
-	wg := new(sync.WaitGroup)
+	e.mu.Lock()
+	toStop := map[string]UnitState{}
 	for id, state := range e.startingUnits {
-		wg.Add(1)
-		go func(id string, state UnitState) {
-			e.stopUnit(id, state)
-			wg.Done()
-		}(id, state)
-		e.mu.Lock()
+		toStop[id] = state
 		delete(e.startingUnits, id)
-		e.mu.Unlock()
-
 	}
-
 	for id, state := range e.runningUnits {
+		toStop[id] = state
+		delete(e.runningUnits, id)
+	}
+	e.mu.Unlock()
+
+	wg := new(sync.WaitGroup)
+	for id, state := range toStop {
 		wg.Add(1)
 		go func(id string, state UnitState) {
 			e.stopUnit(id, state)
 			wg.Done()
 		}(id, state)
-		e.mu.Lock()
-		delete(e.runningUnits, id)
-		e.mu.Unlock()
 	}
 	wg.Wait()
 }
 
 func (e *UnitEngine) genStatsLine(id string, delay time.Duration) statsLine {
-	startTime := time.Now().Add(-delay)
+	startTime := e.clock.Now().Add(-delay)
 
 	return statsLine{
 		ID:             id,
@@ -325,3 +531,10 @@ func (e *UnitEngine) genStatsLine(id string, delay time.Duration) statsLine {
 		StoppedCount:   len(e.stoppedUnits),
 	}
 }
+
+func (e *UnitEngine) genPlacedStatsLine(id string, state UnitState, delay time.Duration) statsLine {
+	line := e.genStatsLine(id, delay)
+	line.Hostname = state.hostname
+	line.MachineID = state.machineID
+	return line
+}
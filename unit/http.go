@@ -2,47 +2,190 @@ package unit
 
 import (
 	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 
+	"github.com/giantswarm/nomi/definition"
 	"github.com/giantswarm/nomi/log"
+	"github.com/giantswarm/nomi/samples"
 )
 
+// AgentBinaryPath is the observer endpoint serving the nomi binary the agent
+// units run
+const AgentBinaryPath = "/agent/binary"
+
+// maxInstructionsSize is the largest body accepted by the control API
+const maxInstructionsSize = 64 * 1024
+
+// liveStatusInterval is how often the status is streamed to the dashboard
+const liveStatusInterval = time.Second
+
+// dashboardFiles are the embedded files served under /live/
+var dashboardFiles = map[string]string{
+	"":          "output/embedded/live.html",
+	"script.js": "output/embedded/script.js",
+	"live.js":   "output/embedded/live.js",
+	"style.css": "output/embedded/style.css",
+}
+
 type UnitObserver struct {
 	unitEngine *UnitEngine
+
+	// assets returns the embedded files of the dashboard
+	assets func(name string) ([]byte, error)
+
+	// controlToken authenticates the requests to the control API, which is
+	// disabled when empty
+	controlToken string
+
+	// callbackToken authenticates the requests of the units, when set
+	callbackToken string
+
+	// certificate is served over TLS when set
+	certificate *tls.Certificate
+
+	// agentBinary is the nomi binary served to the agent units, the running
+	// one when empty
+	agentBinary string
+
+	server *http.Server
+	// closing is closed when the observer shuts down, to end the streams of
+	// the dashboards
+	closing     chan struct{}
+	closingOnce sync.Once
 }
 
 func NewUnitObserver(engine *UnitEngine) *UnitObserver {
 	return &UnitObserver{
 		unitEngine: engine,
+		closing:    make(chan struct{}),
 	}
 }
 
-func (s *UnitObserver) StartHTTPService(addr string) {
-	r := mux.NewRouter()
-	r.HandleFunc("/hello/{unitID}", withIDParam(s.HelloHandler)).Methods("GET")
-	r.HandleFunc("/alive/{unitID}", withIDParam(s.AliveHandler)).Methods("GET")
-	r.HandleFunc("/bye/{unitID}", withIDParam(s.ByeHandler)).Methods("GET")
+// UseDashboard serves the live dashboard on /live out of the embedded files
+func (s *UnitObserver) UseDashboard(assets func(name string) ([]byte, error)) {
+	s.assets = assets
+}
 
-	r.HandleFunc("/stats/{statsID}", s.StatsHandler).Methods("POST")
+// UseControl enables the control API, its requests have to carry the token in
+// an "Authorization: Bearer" header
+func (s *UnitObserver) UseControl(token string) {
+	s.controlToken = token
+}
 
-	http.Handle("/", r)
-	if Verbose {
-		log.Logger().Infof("listening on %s\n", addr)
+// UseCallbackToken rejects the requests of the units without the token of the
+// run, see Builder.UseCallbackToken
+func (s *UnitObserver) UseCallbackToken(token string) {
+	s.callbackToken = token
+}
+
+// UseTLS serves https with the certificate whose public key the units pin
+func (s *UnitObserver) UseTLS(certificate tls.Certificate) {
+	s.certificate = &certificate
+}
+
+// UseAgentBinary serves the nomi binary at path to the agent units instead of
+// the running one, e.g. a linux build when nomi runs from a workstation
+func (s *UnitObserver) UseAgentBinary(path string) {
+	s.agentBinary = path
+}
+
+// StartHTTPService listens on addr and serves the observer in the background.
+// It returns the address the units have to call back to, which has the port
+// picked by the system when the port of addr is 0.
+func (s *UnitObserver) StartHTTPService(addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
 	}
+	if s.certificate != nil {
+		listener = tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{*s.certificate}})
+	}
+	callbackAddr := CallbackAddr(addr, listener.Addr())
 
-	go http.ListenAndServe(addr, nil)
+	s.server = &http.Server{Handler: s.router()}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Logger().Errorf("the observer stopped serving: %v", err)
+		}
+	}()
 	if Verbose {
-		log.Logger().Infof("listening on %s\n", addr)
+		log.Logger().Infof("listening on %s\n", callbackAddr)
+	}
+	return callbackAddr, nil
+}
+
+// Shutdown stops accepting requests and waits for the ones in progress, so
+// that the callbacks of the last units are counted before the report
+func (s *UnitObserver) Shutdown(ctx context.Context) error {
+	s.closingOnce.Do(func() { close(s.closing) })
+	if s.server == nil {
+		return nil
+	}
+	return s.server.Shutdown(ctx)
+}
+
+// CallbackAddr returns the address to reach a listener on: the host asked for
+// and the port it got
+func CallbackAddr(requested string, actual net.Addr) string {
+	host, _, err := net.SplitHostPort(requested)
+	if err != nil {
+		return actual.String()
+	}
+	_, port, err := net.SplitHostPort(actual.String())
+	if err != nil {
+		return actual.String()
 	}
+	return net.JoinHostPort(host, port)
 }
 
-func withIDParam(handler func(unitID string, w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+func (s *UnitObserver) router() *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/hello/{unitID}", s.withIDParam(s.HelloHandler)).Methods("GET")
+	r.HandleFunc("/alive/{unitID}", s.withIDParam(s.AliveHandler)).Methods("GET")
+	r.HandleFunc("/bye/{unitID}", s.withIDParam(s.ByeHandler)).Methods("GET")
+	r.HandleFunc("/metrics/{unitID}", s.withIDParam(s.MetricsHandler)).Methods("POST")
+
+	r.HandleFunc("/status", s.StatusHandler).Methods("GET")
+	r.HandleFunc("/metrics", s.PrometheusHandler).Methods("GET")
+	r.HandleFunc("/live/events", s.LiveEventsHandler).Methods("GET")
+	r.HandleFunc("/live", s.DashboardHandler).Methods("GET")
+	r.HandleFunc("/live/{file}", s.DashboardHandler).Methods("GET")
+
+	if s.controlToken != "" {
+		r.HandleFunc("/control/instructions", s.withControlToken(s.InstructionsHandler)).Methods("POST")
+		r.HandleFunc("/control/{action:pause|resume|abort}", s.withControlToken(s.ControlHandler)).Methods("POST")
+	}
+
+	r.HandleFunc("/stats/{statsID}", s.withCallbackToken(s.StatsHandler)).Methods("POST")
+	r.HandleFunc(samples.PathPrefix+"{source}", s.withCallbackToken(s.SamplesHandler)).Methods("POST")
+	r.HandleFunc(AgentBinaryPath, s.withCallbackToken(s.AgentBinaryHandler)).Methods("GET")
+
+	return r
+}
+
+func (s *UnitObserver) withIDParam(handler func(unitID string, w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		unitID := mux.Vars(r)["unitID"]
-		if unitID == "" {
+		if !s.validCallbackToken(r) {
+			log.Logger().Warningf("rejected a request for unit %s with a wrong token from %s", unitID, r.RemoteAddr)
+			w.WriteHeader(401)
+		} else if unitID == "" {
 			log.Logger().Error("empty unitID")
 			w.WriteHeader(400)
 		} else {
@@ -51,8 +194,43 @@ func withIDParam(handler func(unitID string, w http.ResponseWriter, r *http.Requ
 	}
 }
 
+// withCallbackToken rejects the requests of the units without the token of
+// the run
+func (s *UnitObserver) withCallbackToken(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.validCallbackToken(r) {
+			log.Logger().Warningf("rejected a request to %s with a wrong token from %s", r.URL.Path, r.RemoteAddr)
+			w.WriteHeader(401)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (s *UnitObserver) validCallbackToken(r *http.Request) bool {
+	if s.callbackToken == "" {
+		return true
+	}
+	token := r.URL.Query().Get(CallbackTokenParam)
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.callbackToken)) == 1
+}
+
+// withControlToken rejects the requests without the token of the control API
+func (s *UnitObserver) withControlToken(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.controlToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "invalid control token", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
 func (s *UnitObserver) HelloHandler(unitID string, w http.ResponseWriter, r *http.Request) {
-	delay := s.unitEngine.MarkUnitRunning(unitID)
+	query := r.URL.Query()
+	delay := s.unitEngine.MarkUnitRunning(unitID, query.Get("host"), query.Get("machine"))
 	if Verbose {
 		log.Logger().Infof("marked unit as running: %s [%d] %f", unitID, len(s.unitEngine.runningUnits), delay.Seconds())
 	}
@@ -70,10 +248,187 @@ func (s *UnitObserver) ByeHandler(unitID string, w http.ResponseWriter, r *http.
 	if Verbose {
 		log.Logger().Infof("marking unit as stopped: %s [%d]", unitID, len(s.unitEngine.stoppedUnits)+len(s.unitEngine.runningUnits)+len(s.unitEngine.startingUnits))
 	}
-	s.unitEngine.MarkUnitStopped(unitID)
+	query := r.URL.Query()
+	s.unitEngine.MarkUnitStopped(unitID, query.Get("host"), query.Get("machine"))
+	w.Write([]byte("ok.\n"))
+}
+
+// ControlHandler pauses, resumes or aborts the benchmark
+func (s *UnitObserver) ControlHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	action := mux.Vars(r)["action"]
+	switch action {
+	case "pause":
+		err = s.unitEngine.Pause()
+	case "resume":
+		err = s.unitEngine.Resume()
+	case "abort":
+		err = s.unitEngine.Abort()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	log.Logger().Infof("control: %s", action)
+	w.Write([]byte("ok.\n"))
+}
+
+// InstructionsHandler queues the raw instructions of the request body into the
+// running benchmark
+func (s *UnitObserver) InstructionsHandler(w http.ResponseWriter, r *http.Request) {
+	raw, err := ioutil.ReadAll(io.LimitReader(r.Body, maxInstructionsSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.unitEngine.QueueInstructions(string(raw)); err != nil {
+		status := http.StatusBadRequest
+		if err == errAborted || err == errFinished {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	log.Logger().Infof("control: queued %s", raw)
+	w.Write([]byte("ok.\n"))
+}
+
+// StatusHandler returns the state of the benchmark in progress as JSON
+func (s *UnitObserver) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.unitEngine.Status())
+}
+
+// PrometheusHandler exposes the state of the benchmark in progress to
+// Prometheus
+func (s *UnitObserver) PrometheusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.unitEngine.WritePrometheus(w)
+}
+
+// DashboardHandler serves the files of the live dashboard
+func (s *UnitObserver) DashboardHandler(w http.ResponseWriter, r *http.Request) {
+	name, known := dashboardFiles[mux.Vars(r)["file"]]
+	if !known || s.assets == nil {
+		http.NotFound(w, r)
+		return
+	}
+	content, err := s.assets(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if strings.HasSuffix(name, ".js") {
+		w.Header().Set("Content-Type", "application/javascript")
+	} else if strings.HasSuffix(name, ".css") {
+		w.Header().Set("Content-Type", "text/css")
+	} else {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	w.Write(content)
+}
+
+// LiveEventsHandler streams the collected metrics as server-sent events: a
+// snapshot first, then every change and the status every second. The stream
+// ends when the client does not keep up, browsers reconnect on their own and
+// get a new snapshot.
+func (s *UnitObserver) LiveEventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", 500)
+		return
+	}
+
+	snapshot, events, unsubscribe := s.unitEngine.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	writeServerSentEvent(w, "snapshot", snapshot)
+	writeServerSentEvent(w, "status", s.unitEngine.Status())
+	flusher.Flush()
+
+	var closed <-chan bool
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		closed = notifier.CloseNotify()
+	}
+	ticker := time.NewTicker(liveStatusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev, open := <-events:
+			if !open {
+				return
+			}
+			writeServerSentEvent(w, ev.Type, ev.Data)
+		case <-ticker.C:
+			writeServerSentEvent(w, "status", s.unitEngine.Status())
+		case <-closed:
+			return
+		case <-s.closing:
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func writeServerSentEvent(w io.Writer, name string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Logger().Warningf("unable to encode the %s event: %v", name, err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, payload)
+}
+
+// MetricsHandler collects the measurements of the application an unit runs,
+// either a JSON object or form values mapping their names to numbers
+func (s *UnitObserver) MetricsHandler(unitID string, w http.ResponseWriter, r *http.Request) {
+	metrics, err := parseMetrics(r)
+	if err != nil {
+		log.Logger().Warningf("wrong metrics from unit %s: %v", unitID, err)
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	s.unitEngine.DumpAppMetrics(unitID, metrics)
 	w.Write([]byte("ok.\n"))
 }
 
+func parseMetrics(r *http.Request) (map[string]float64, error) {
+	metrics := map[string]float64{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&metrics); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+		for name, values := range r.Form {
+			if name == CallbackTokenParam {
+				continue
+			}
+			value, err := strconv.ParseFloat(values[0], 64)
+			if err != nil {
+				return nil, fmt.Errorf("metric %s is not a number: %q", name, values[0])
+			}
+			metrics[name] = value
+		}
+	}
+
+	if len(metrics) == 0 {
+		return nil, fmt.Errorf("no metrics")
+	}
+	for name := range metrics {
+		if !definition.MetricNameRegexp.MatchString(name) {
+			return nil, fmt.Errorf("wrong metric name %q", name)
+		}
+	}
+	return metrics, nil
+}
+
+// StatsHandler parses the legacy "<hostname> <cpu usage> <rss>" stats lines,
+// kept for the stats dumpers of older versions
 func (s *UnitObserver) StatsHandler(w http.ResponseWriter, r *http.Request) {
 	statsID := mux.Vars(r)["statsID"]
 	b := bytes.NewBufferString("")
@@ -91,3 +446,40 @@ func (s *UnitObserver) StatsHandler(w http.ResponseWriter, r *http.Request) {
 
 	s.unitEngine.DumpProcessStats(statsID, hostname, cpuusage, rss)
 }
+
+// SamplesHandler stores a JSON batch of samples, see the samples package
+func (s *UnitObserver) SamplesHandler(w http.ResponseWriter, r *http.Request) {
+	source := mux.Vars(r)["source"]
+	var batch samples.Batch
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		log.Logger().Warningf("don't know how to parse the samples of %s: %v", source, err)
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if err := batch.Validate(); err != nil {
+		log.Logger().Warningf("wrong samples from %s: %v", source, err)
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	s.unitEngine.DumpSamples(source, batch)
+	w.Write([]byte("ok.\n"))
+}
+
+// AgentBinaryHandler serves the nomi binary to the agent units, so the
+// machines don't need nomi installed
+func (s *UnitObserver) AgentBinaryHandler(w http.ResponseWriter, r *http.Request) {
+	path := s.agentBinary
+	if path == "" {
+		path = ExecutablePath()
+	}
+	http.ServeFile(w, r, path)
+}
+
+// ExecutablePath returns the path of the running nomi binary
+func ExecutablePath() string {
+	if path, err := os.Readlink("/proc/self/exe"); err == nil {
+		return path
+	}
+	path, _ := filepath.Abs(os.Args[0])
+	return path
+}
@@ -0,0 +1,91 @@
+package unit
+
+import (
+	"bufio"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/nomi/definition"
+)
+
+func TestObserverLifecycle(t *testing.T) {
+	def, _ := definition.BenchmarkDefByRawInstructions("(start 1 0)", 1)
+	engine, _ := NewEngine(def, false)
+	engine.startTime = time.Now()
+	engine.startingUnits["a"] = UnitState{startRequestTime: engine.startTime}
+
+	observer := NewUnitObserver(engine)
+	addr, err := observer.StartHTTPService("127.0.0.1:0")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !strings.HasPrefix(addr, "127.0.0.1:") || strings.HasSuffix(addr, ":0") {
+		log.Fatalf("expected the port picked by the system, got: %s", addr)
+	}
+	if _, err := NewUnitObserver(engine).StartHTTPService(addr); err == nil {
+		log.Fatalf("a second observer listens on the same address %s", addr)
+	}
+
+	// a client of its own, which leaves no idle connection behind: the
+	// shutdown only closes a connection that never sent a request after 5s
+	transport := &http.Transport{DisableKeepAlives: true}
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get("http://" + addr + "/hello/a")
+	if err != nil {
+		log.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(engine.Stats().Start) != 1 {
+		log.Fatalf("the unit was not marked as running")
+	}
+
+	// the stream of a dashboard must not hold the shutdown
+	stream, err := client.Get("http://" + addr + "/live/events")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stream.Body.Close()
+	if _, err := bufio.NewReader(stream.Body).ReadString('\n'); err != nil {
+		log.Fatal(err)
+	}
+
+	transport.CloseIdleConnections()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := observer.Shutdown(ctx); err != nil {
+		log.Fatalf("the observer did not shut down: %v", err)
+	}
+	if _, err := client.Get("http://" + addr + "/status"); err == nil {
+		log.Fatalf("the observer still answers after shutting down")
+	}
+}
+
+func TestAgentBinaryToken(t *testing.T) {
+	def, _ := definition.BenchmarkDefByRawInstructions("(start 1 0)", 1)
+	engine, _ := NewEngine(def, false)
+	observer := NewUnitObserver(engine)
+	observer.UseCallbackToken("s3cret")
+	router := observer.router()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", AgentBinaryPath, nil))
+	if w.Code != 401 {
+		log.Fatalf("expected the binary to be refused without the token, got: %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", AgentBinaryPath+"?"+CallbackTokenParam+"=wrong", nil))
+	if w.Code != 401 {
+		log.Fatalf("expected the binary to be refused with a wrong token, got: %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", AgentBinaryPath+"?"+CallbackTokenParam+"=s3cret", nil))
+	if w.Code != 200 || w.Body.Len() == 0 {
+		log.Fatalf("expected the binary with the token, got: %d", w.Code)
+	}
+}
@@ -0,0 +1,43 @@
+package unit
+
+// liveBuffer is the number of events a subscriber can lag behind before it is
+// dropped
+const liveBuffer = 1024
+
+// LiveEvent is a change of the collected metrics: a start, stop or failed
+// stats line, an instruction of the event log, or process stats per machine
+type LiveEvent struct {
+	Type string
+	Data interface{}
+}
+
+// Subscribe returns the metrics collected so far and the channel the
+// following changes are sent to, until unsubscribe is called. The channel is
+// closed when the subscriber does not keep up, it should subscribe again.
+func (e *UnitEngine) Subscribe() (snapshot Stats, events <-chan LiveEvent, unsubscribe func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ch := make(chan LiveEvent, liveBuffer)
+	e.subscribers[ch] = true
+	return e.statsLocked(), ch, func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if e.subscribers[ch] {
+			delete(e.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publish sends an event to the subscribers, the caller holds the lock
+func (e *UnitEngine) publish(ev LiveEvent) {
+	for ch := range e.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			delete(e.subscribers, ch)
+			close(ch)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package unit
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/nomi/definition"
+	"github.com/giantswarm/nomi/samples"
+)
+
+func TestSubscribe(t *testing.T) {
+	def, _ := definition.BenchmarkDefByRawInstructions("(start 1 0)", 1)
+	engine, _ := NewEngine(def, false)
+	engine.startTime = time.Now()
+	engine.startingUnits["a"] = UnitState{startRequestTime: engine.startTime}
+	engine.startingUnits["b"] = UnitState{startRequestTime: engine.startTime}
+	engine.MarkUnitRunning("a", "core-1", "m1")
+
+	snapshot, events, unsubscribe := engine.Subscribe()
+	if len(snapshot.Start) != 1 {
+		log.Fatalf("expected the started unit in the snapshot, got: %+v", snapshot.Start)
+	}
+
+	engine.MarkUnitRunning("b", "core-2", "m2")
+	engine.DumpSamples("agent", samples.Batch{Samples: []samples.Sample{
+		{Host: "core-1", Timestamp: engine.startTime, Metrics: map[string]float64{samples.Load1: 1}},
+		{Host: "core-1", Process: "fleetd", Timestamp: engine.startTime, Metrics: map[string]float64{samples.CPUUsage: 3}},
+	}})
+
+	ev := <-events
+	if line, ok := ev.Data.(statsLine); ev.Type != "start" || !ok || line.ID != "b" || line.Hostname != "core-2" {
+		log.Fatalf("expected the start of b, got: %+v", ev)
+	}
+	ev = <-events
+	machineStats, ok := ev.Data.(map[string][]processStatsLine)
+	if ev.Type != "machine-stats" || !ok || len(machineStats["core-1"]) != 1 || machineStats["core-1"][0].CPUUsage != 3 {
+		log.Fatalf("expected the fleetd stats of core-1, got: %+v", ev)
+	}
+
+	// a subscriber which does not keep up is dropped
+	for i := 0; i <= liveBuffer; i++ {
+		engine.logCommand("sleep", nil, engine.startTime, engine.startTime)
+	}
+	received := 0
+	for range events {
+		received++
+	}
+	if received != liveBuffer {
+		log.Fatalf("expected %d buffered events before the channel is closed, got %d", liveBuffer, received)
+	}
+	unsubscribe()
+}
@@ -0,0 +1,95 @@
+package unit
+
+import (
+	"sort"
+	"time"
+
+	"github.com/giantswarm/nomi/backend"
+	"github.com/giantswarm/nomi/definition"
+	"github.com/giantswarm/nomi/log"
+)
+
+// Metadata describes where the results of a benchmark come from
+type Metadata struct {
+	RunID      string
+	Version    string
+	Build      string
+	Backend    string
+	Definition definition.BenchmarkDef
+	Before     *ClusterSnapshot
+	After      *ClusterSnapshot
+}
+
+// ClusterSnapshot describes the topology of the cluster at some point in time
+type ClusterSnapshot struct {
+	Time          time.Time
+	Machines      []backend.Machine
+	UnitCount     int
+	FleetVersions []string
+	EtcdVersion   string
+}
+
+// TakeClusterSnapshot collects the machines and the amount of units of the
+// cluster behind a backend, at the time of the clock of the engine. The
+// machines are only listed when the backend is able to.
+func (e *UnitEngine) TakeClusterSnapshot(b backend.Backend) *ClusterSnapshot {
+	snapshot := &ClusterSnapshot{
+		Time:          e.clock.Now(),
+		Machines:      []backend.Machine{},
+		FleetVersions: []string{},
+	}
+
+	if lister, ok := b.(backend.MachineLister); ok {
+		machines, err := lister.Machines()
+		if err != nil {
+			log.Logger().Warningf("unable to list the machines of the cluster: %v", err)
+		} else {
+			snapshot.Machines = machines
+		}
+	}
+
+	versions := map[string]bool{}
+	for _, machine := range snapshot.Machines {
+		if machine.Version != "" && !versions[machine.Version] {
+			versions[machine.Version] = true
+			snapshot.FleetVersions = append(snapshot.FleetVersions, machine.Version)
+		}
+	}
+	sort.Strings(snapshot.FleetVersions)
+
+	units, err := b.ListUnits()
+	if err != nil {
+		log.Logger().Warningf("unable to list the units of the cluster: %v", err)
+	} else {
+		snapshot.UnitCount = len(units)
+	}
+
+	return snapshot
+}
+
+// SetMetadata attaches the description of the benchmark and the cluster to the
+// collected metrics
+func (e *UnitEngine) SetMetadata(metadata Metadata) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.metadata = metadata
+}
+
+// machines returns the machines of the cluster seen before or after the
+// benchmark
+func (m Metadata) machines() []backend.Machine {
+	machines := []backend.Machine{}
+	seen := map[string]bool{}
+	for _, snapshot := range []*ClusterSnapshot{m.Before, m.After} {
+		if snapshot == nil {
+			continue
+		}
+		for _, machine := range snapshot.Machines {
+			if !seen[machine.ID] {
+				seen[machine.ID] = true
+				machines = append(machines, machine)
+			}
+		}
+	}
+	return machines
+}
@@ -0,0 +1,218 @@
+package unit
+
+import (
+	"strings"
+	"time"
+
+	"github.com/coreos/fleet/schema"
+
+	"github.com/giantswarm/nomi/definition"
+	"github.com/giantswarm/nomi/log"
+)
+
+// stopWaitInterval is how often WaitStopped checks the stopping units
+const stopWaitInterval = 500 * time.Millisecond
+
+// UnitLister is implemented by the backends able to report where the units
+// were scheduled and in which state they are
+type UnitLister interface {
+	ListUnits() ([]*schema.Unit, error)
+	UnitStates() ([]*schema.UnitState, error)
+}
+
+// unitPhases collects when all the units of an instance group went through
+// each phase of the start operation
+type unitPhases struct {
+	submitted time.Time
+	scheduled time.Time
+	loaded    time.Time
+	launched  time.Time
+	active    time.Time
+}
+
+type phaseCounts struct {
+	scheduled int
+	loaded    int
+	launched  int
+	active    int
+
+	// units not inactive nor failed yet, and the machine of the group
+	alive     int
+	machineID string
+}
+
+// WatchPhases polls the backend every interval to record when the benchmark
+// units get a machine, are loaded, launched and active. It returns a function
+// that stops the watcher.
+func (e *UnitEngine) WatchPhases(lister UnitLister, prefix string, interval time.Duration) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			e.pollPhases(lister, prefix)
+			e.clock.Sleep(interval)
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+func (e *UnitEngine) pollPhases(lister UnitLister, prefix string) {
+	units, err := lister.ListUnits()
+	if err != nil {
+		log.Logger().Warningf("unable to list units: %v", err)
+		return
+	}
+	states, err := lister.UnitStates()
+	if err != nil {
+		log.Logger().Warningf("unable to list unit states: %v", err)
+		return
+	}
+
+	counts := map[string]*phaseCounts{}
+	countsOf := func(name string) *phaseCounts {
+		id, ok := groupID(prefix, name)
+		if !ok {
+			return nil
+		}
+		if _, exists := counts[id]; !exists {
+			counts[id] = &phaseCounts{}
+		}
+		return counts[id]
+	}
+
+	for _, unit := range units {
+		c := countsOf(unit.Name)
+		if c == nil {
+			continue
+		}
+		if unit.MachineID != "" {
+			c.scheduled++
+		}
+		if unit.CurrentState == "loaded" || unit.CurrentState == "launched" {
+			c.loaded++
+		}
+		if unit.CurrentState == "launched" {
+			c.launched++
+		}
+	}
+	for _, state := range states {
+		c := countsOf(state.Name)
+		if c == nil {
+			continue
+		}
+		if state.SystemdActiveState == "active" {
+			c.active++
+		}
+		if state.SystemdActiveState != "inactive" && state.SystemdActiveState != "failed" {
+			c.alive++
+		}
+		c.machineID = state.MachineID
+	}
+
+	now := e.clock.Now()
+	size := e.benchmark.InstanceGroupSize
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for id, c := range counts {
+		phases, exists := e.phases[id]
+		if !exists {
+			continue
+		}
+		reached := func(t *time.Time, count int) {
+			if t.IsZero() && count >= size {
+				*t = now
+			}
+		}
+		reached(&phases.scheduled, c.scheduled)
+		reached(&phases.loaded, c.loaded)
+		reached(&phases.launched, c.launched)
+		reached(&phases.active, c.active)
+	}
+
+	if e.benchmark.Application.Notifier == definition.NotifierState {
+		e.inferStates(counts, now)
+	}
+}
+
+// inferStates marks the instance groups as running once all their units are
+// active, and as stopped once none of them is alive anymore, for the units
+// which don't call back. The caller holds the lock.
+func (e *UnitEngine) inferStates(counts map[string]*phaseCounts, now time.Time) {
+	for id := range e.startingUnits {
+		if c, exists := counts[id]; exists && c.active >= e.benchmark.InstanceGroupSize {
+			e.markUnitRunningLocked(id, "", c.machineID, now)
+		}
+	}
+	for id := range e.stoppingUnits {
+		if c, exists := counts[id]; !exists || c.alive == 0 {
+			e.markUnitStoppedLocked(id, "", "", now)
+		}
+	}
+}
+
+// WaitStopped waits for the stopping units to be reported as stopped, up to
+// timeout. It tells whether they all were.
+func (e *UnitEngine) WaitStopped(timeout time.Duration) bool {
+	deadline := e.clock.Now().Add(timeout)
+	for {
+		e.mu.Lock()
+		stopping := len(e.stoppingUnits)
+		e.mu.Unlock()
+		if stopping == 0 {
+			return true
+		}
+		if e.clock.Now().After(deadline) {
+			return false
+		}
+		e.clock.Sleep(stopWaitInterval)
+	}
+}
+
+// groupID extracts the instance group ID out of the name of a benchmark unit,
+// <prefix>-<index>@<id>.service
+func groupID(prefix, name string) (string, bool) {
+	if !strings.HasPrefix(name, prefix+"-") {
+		return "", false
+	}
+	at := strings.LastIndex(name, "@")
+	if at < 0 || !strings.HasSuffix(name, ".service") {
+		return "", false
+	}
+	return strings.TrimSuffix(name[at+1:], ".service"), true
+}
+
+// withPhases fills the phase timestamps of the start operation in a copy of
+// the stats lines
+func (e *UnitEngine) withPhases(lines stats) stats {
+	filled := make(stats, len(lines))
+	for i, line := range lines {
+		if phases, exists := e.phases[line.ID]; exists {
+			line.SubmittedTime = e.sinceStart(phases.submitted)
+			line.ScheduledTime = e.sinceStart(phases.scheduled)
+			line.LoadedTime = e.sinceStart(phases.loaded)
+			line.LaunchedTime = e.sinceStart(phases.launched)
+			line.ActiveTime = e.sinceStart(phases.active)
+		}
+		filled[i] = line
+	}
+	return filled
+}
+
+func (e *UnitEngine) sinceStart(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Sub(e.startTime).Seconds()
+}
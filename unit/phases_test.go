@@ -0,0 +1,78 @@
+package unit
+
+import (
+	"log"
+	"testing"
+
+	"github.com/coreos/fleet/schema"
+
+	"github.com/giantswarm/nomi/definition"
+)
+
+type staticLister struct {
+	units  []*schema.Unit
+	states []*schema.UnitState
+}
+
+func (l staticLister) ListUnits() ([]*schema.Unit, error)       { return l.units, nil }
+func (l staticLister) UnitStates() ([]*schema.UnitState, error) { return l.states, nil }
+
+func TestPollPhases(t *testing.T) {
+	def, _ := definition.BenchmarkDefByRawInstructions("(start 1 0)", 2)
+	engine, _ := NewEngine(def, false)
+	engine.phases["abc"] = &unitPhases{}
+
+	lister := staticLister{
+		units: []*schema.Unit{
+			{Name: "nomi-0@abc.service", MachineID: "m1", CurrentState: "launched"},
+			{Name: "nomi-1@abc.service", MachineID: "m1", CurrentState: "loaded"},
+			{Name: "other-0@abc.service", MachineID: "m1", CurrentState: "launched"},
+		},
+		states: []*schema.UnitState{
+			{Name: "nomi-0@abc.service", SystemdActiveState: "active"},
+		},
+	}
+	engine.pollPhases(lister, "nomi")
+
+	phases := engine.phases["abc"]
+	if phases.scheduled.IsZero() || phases.loaded.IsZero() {
+		log.Fatalf("expected the group to be scheduled and loaded")
+	}
+	if !phases.launched.IsZero() || !phases.active.IsZero() {
+		log.Fatalf("expected the group not to be launched nor active until all its units are")
+	}
+}
+
+func TestInferStates(t *testing.T) {
+	def, _ := definition.BenchmarkDefByRawInstructions("(start 1 0)", 2)
+	def.Application.Notifier = definition.NotifierState
+	engine, _ := NewEngine(def, false)
+	engine.startTime = engine.clock.Now()
+	engine.startingUnits["abc"] = UnitState{startRequestTime: engine.startTime}
+	engine.phases["abc"] = &unitPhases{}
+	engine.StopFunc = func(id string) error { return nil }
+
+	active := staticLister{
+		states: []*schema.UnitState{
+			{Name: "nomi-0@abc.service", MachineID: "m1", SystemdActiveState: "active"},
+			{Name: "nomi-1@abc.service", MachineID: "m1", SystemdActiveState: "active"},
+		},
+	}
+	engine.pollPhases(active, "nomi")
+	if _, running := engine.runningUnits["abc"]; !running {
+		log.Fatalf("expected the group to be running once all its units are active")
+	}
+
+	engine.stopUnit("abc", engine.runningUnits["abc"])
+	delete(engine.runningUnits, "abc")
+	engine.pollPhases(active, "nomi")
+	if len(engine.stoppedStats) != 0 {
+		log.Fatalf("the group was stopped while its units were active")
+	}
+
+	engine.pollPhases(staticLister{}, "nomi")
+	stats := engine.Stats()
+	if len(stats.Stop) != 1 || stats.Stop[0].MachineID != "m1" {
+		log.Fatalf("expected the group to be stopped on m1, got: %+v", stats.Stop)
+	}
+}
@@ -0,0 +1,86 @@
+package unit
+
+import (
+	"math"
+	"sort"
+
+	"github.com/giantswarm/nomi/backend"
+)
+
+type machinePlacement struct {
+	Hostname  string
+	MachineID string
+	Units     int
+	MeanDelay float64
+	MaxDelay  float64
+}
+
+type byHostname []machinePlacement
+
+func (p byHostname) Len() int           { return len(p) }
+func (p byHostname) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byHostname) Less(i, j int) bool { return p[i].Hostname < p[j].Hostname }
+
+// placementOf groups the started instance groups by the machine they landed
+// on. It also returns how imbalanced the placement is: the ratio between the
+// busiest machine and the mean, and the standard deviation of the units per
+// machine. The machines of the cluster that got no units are part of the
+// placement with 0 units.
+func placementOf(lines stats, machines []backend.Machine) ([]machinePlacement, float64, float64) {
+	byMachine := map[string]*machinePlacement{}
+	for _, line := range lines {
+		if line.Hostname == "" && line.MachineID == "" {
+			continue
+		}
+		key := line.MachineID + "/" + line.Hostname
+		p, exists := byMachine[key]
+		if !exists {
+			p = &machinePlacement{Hostname: line.Hostname, MachineID: line.MachineID}
+			byMachine[key] = p
+		}
+		p.Units++
+		p.MeanDelay += line.Delay
+		p.MaxDelay = math.Max(p.MaxDelay, line.Delay)
+	}
+	if len(byMachine) == 0 {
+		return []machinePlacement{}, 0, 0
+	}
+
+	placed := map[string]bool{}
+	for _, p := range byMachine {
+		placed[p.MachineID] = true
+	}
+	for _, m := range machines {
+		if placed[m.ID] {
+			continue
+		}
+		hostname := m.Metadata["hostname"]
+		if hostname == "" {
+			hostname = m.PublicIP
+		}
+		placed[m.ID] = true
+		byMachine[m.ID+"/"] = &machinePlacement{Hostname: hostname, MachineID: m.ID}
+	}
+
+	placement := []machinePlacement{}
+	total, busiest := 0, 0
+	for _, p := range byMachine {
+		if p.Units > 0 {
+			p.MeanDelay /= float64(p.Units)
+		}
+		total += p.Units
+		if p.Units > busiest {
+			busiest = p.Units
+		}
+		placement = append(placement, *p)
+	}
+	sort.Sort(byHostname(placement))
+
+	mean := float64(total) / float64(len(placement))
+	variance := 0.0
+	for _, p := range placement {
+		variance += math.Pow(float64(p.Units)-mean, 2)
+	}
+	stddev := math.Sqrt(variance / float64(len(placement)))
+	return placement, float64(busiest) / mean, stddev
+}
@@ -0,0 +1,51 @@
+package unit
+
+import (
+	"log"
+	"math"
+	"testing"
+
+	"github.com/giantswarm/nomi/backend"
+)
+
+func TestPlacement(t *testing.T) {
+	lines := stats{
+		{ID: "a", Delay: 1, Hostname: "host-1", MachineID: "m1"},
+		{ID: "b", Delay: 3, Hostname: "host-1", MachineID: "m1"},
+		{ID: "c", Delay: 2, Hostname: "host-1", MachineID: "m1"},
+		{ID: "d", Delay: 5, Hostname: "host-2", MachineID: "m2"},
+		{ID: "e", Delay: 9},
+	}
+
+	placement, imbalance, stddev := placementOf(lines, nil)
+	if len(placement) != 2 {
+		log.Fatalf("expected 2 machines got %d", len(placement))
+	}
+	if placement[0].Hostname != "host-1" || placement[0].Units != 3 || placement[0].MeanDelay != 2 || placement[0].MaxDelay != 3 {
+		log.Fatalf("unexpected placement for host-1: %+v", placement[0])
+	}
+	if imbalance != 1.5 || stddev != 1 {
+		log.Fatalf("unexpected imbalance %v stddev %v", imbalance, stddev)
+	}
+}
+
+func TestPlacementIdleMachines(t *testing.T) {
+	lines := stats{
+		{ID: "a", Delay: 1, Hostname: "host-1", MachineID: "m1"},
+		{ID: "b", Delay: 1, Hostname: "host-1", MachineID: "m1"},
+		{ID: "c", Delay: 1, Hostname: "host-1", MachineID: "m1"},
+		{ID: "d", Delay: 1, Hostname: "host-1", MachineID: "m1"},
+	}
+	machines := []backend.Machine{{ID: "m1"}, {ID: "m2", PublicIP: "10.0.0.2"}, {ID: "m3", Metadata: map[string]string{"hostname": "host-3"}}, {ID: "m4"}}
+
+	placement, imbalance, stddev := placementOf(lines, machines)
+	if len(placement) != 4 {
+		log.Fatalf("expected the idle machines in the placement, got: %+v", placement)
+	}
+	if placement[1].Hostname != "10.0.0.2" || placement[1].Units != 0 || placement[2].Hostname != "host-1" {
+		log.Fatalf("unexpected placement: %+v", placement)
+	}
+	if imbalance != 4 || stddev != math.Sqrt(3) {
+		log.Fatalf("unexpected imbalance %v stddev %v", imbalance, stddev)
+	}
+}
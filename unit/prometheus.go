@@ -0,0 +1,158 @@
+package unit
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// delayBuckets are the upper bounds in seconds of the buckets of the delay
+// histograms
+var delayBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+var invalidMetricChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// WritePrometheus writes the status of the benchmark and the histograms of
+// all the delays in the Prometheus text format
+func (e *UnitEngine) WritePrometheus(w io.Writer) {
+	status := e.Status()
+	e.mu.Lock()
+	startDelays := lastDelays(e.startedStats, len(e.startedStats))
+	stopDelays := lastDelays(e.stoppedStats, len(e.stoppedStats))
+	e.mu.Unlock()
+
+	writeHeader(w, "nomi_elapsed_seconds", "gauge", "Seconds since the benchmark started.")
+	writeSample(w, "nomi_elapsed_seconds", nil, status.Elapsed)
+	writeHeader(w, "nomi_instruction", "gauge", "Index of the instruction being run, the number of instructions once the benchmark ended.")
+	writeSample(w, "nomi_instruction", nil, float64(status.Instruction.Index))
+	writeHeader(w, "nomi_instructions", "gauge", "Number of instructions of the benchmark.")
+	writeSample(w, "nomi_instructions", nil, float64(status.Instruction.Total))
+
+	writeHeader(w, "nomi_units", "gauge", "Number of units per state.")
+	for _, count := range []struct {
+		state string
+		units int
+	}{
+		{"starting", status.Units.Starting},
+		{"running", status.Units.Running},
+		{"stopping", status.Units.Stopping},
+		{"stopped", status.Units.Stopped},
+		{"failed", status.Units.Failed},
+	} {
+		writeSample(w, "nomi_units", []string{"state", count.state}, float64(count.units))
+	}
+
+	writeHistogram(w, "nomi_start_delay_seconds", "Delay of the start operations.", startDelays)
+	writeHistogram(w, "nomi_stop_delay_seconds", "Delay of the stop operations.", stopDelays)
+	writeWindow(w, "nomi_start_delay_window_seconds", fmt.Sprintf("Percentiles of the delay of the last %d start operations.", statusWindow), status.StartDelay)
+	writeWindow(w, "nomi_stop_delay_window_seconds", fmt.Sprintf("Percentiles of the delay of the last %d stop operations.", statusWindow), status.StopDelay)
+
+	writeMachines(w, status.Machines)
+}
+
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// writeSample writes a sample of a metric, labels holds name and value pairs
+func writeSample(w io.Writer, name string, labels []string, value float64) {
+	pairs := []string{}
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+"=\""+escapeLabel(labels[i+1])+"\"")
+	}
+	if len(pairs) > 0 {
+		name += "{" + strings.Join(pairs, ",") + "}"
+	}
+	fmt.Fprintf(w, "%s %s\n", name, formatValue(value))
+}
+
+func writeHistogram(w io.Writer, name, help string, values []float64) {
+	writeHeader(w, name, "histogram", help)
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	for _, bound := range delayBuckets {
+		count := 0
+		for _, v := range values {
+			if v <= bound {
+				count++
+			}
+		}
+		writeSample(w, name+"_bucket", []string{"le", formatValue(bound)}, float64(count))
+	}
+	writeSample(w, name+"_bucket", []string{"le", "+Inf"}, float64(len(values)))
+	writeSample(w, name+"_sum", nil, sum)
+	writeSample(w, name+"_count", nil, float64(len(values)))
+}
+
+func writeWindow(w io.Writer, name, help string, p delayPercentiles) {
+	writeHeader(w, name, "gauge", help)
+	writeSample(w, name, []string{"quantile", "0.5"}, p.P50)
+	writeSample(w, name, []string{"quantile", "0.9"}, p.P90)
+	writeSample(w, name, []string{"quantile", "0.99"}, p.P99)
+	writeSample(w, name, []string{"quantile", "1"}, p.Max)
+}
+
+// writeMachines writes the last sample of every machine, a gauge per metric
+func writeMachines(w io.Writer, machines []machineStatus) {
+	gauges := map[string][]labeledValue{}
+	for _, m := range machines {
+		for metric, value := range m.Host {
+			name := "nomi_host_" + metricName(metric)
+			gauges[name] = append(gauges[name], labeledValue{[]string{"host", m.Hostname}, value})
+		}
+		for process, metrics := range m.Processes {
+			for metric, value := range metrics {
+				name := "nomi_process_" + metricName(metric)
+				gauges[name] = append(gauges[name], labeledValue{[]string{"host", m.Hostname, "process", process}, value})
+			}
+		}
+	}
+
+	names := []string{}
+	for name := range gauges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		all := gauges[name]
+		sort.Sort(byLabels(all))
+		writeHeader(w, name, "gauge", "Last sample pushed by the machines.")
+		for _, s := range all {
+			writeSample(w, name, s.labels, s.value)
+		}
+	}
+}
+
+type labeledValue struct {
+	labels []string
+	value  float64
+}
+
+type byLabels []labeledValue
+
+func (s byLabels) Len() int      { return len(s) }
+func (s byLabels) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byLabels) Less(i, j int) bool {
+	return strings.Join(s[i].labels, "\x00") < strings.Join(s[j].labels, "\x00")
+}
+
+func metricName(metric string) string {
+	return strings.ToLower(invalidMetricChars.ReplaceAllString(metric, "_"))
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
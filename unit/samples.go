@@ -0,0 +1,121 @@
+package unit
+
+import (
+	"time"
+
+	"github.com/giantswarm/nomi/samples"
+)
+
+// metricSample is a sample pushed by a source, kept with the time it was taken
+// at until the stats are computed
+type metricSample struct {
+	source string
+	sample samples.Sample
+}
+
+type metricLine struct {
+	Source    string
+	Host      string
+	Process   string
+	TimeStamp float64
+	Metrics   map[string]float64
+}
+
+type hostStatsLine struct {
+	TimeStamp    float64
+	Load1        float64
+	Load5        float64
+	Load15       float64
+	MemTotal     int
+	MemAvailable int
+	SystemdUnits int
+}
+
+// DumpSamples stores the samples of a source whatever metrics they carry
+func (e *UnitEngine) DumpSamples(source string, batch samples.Batch) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	machineStats := map[string][]processStatsLine{}
+	for _, sample := range batch.Samples {
+		s := metricSample{source: source, sample: sample}
+		e.samples = append(e.samples, s)
+		if sample.Process != "" {
+			machineStats[sample.Host] = append(machineStats[sample.Host], e.processStatsLineOf(s))
+		}
+	}
+	if len(machineStats) > 0 {
+		e.publish(LiveEvent{Type: "machine-stats", Data: machineStats})
+	}
+}
+
+// DumpProcessStats dumps the machine stats for the process systemd and fleetd
+func (e *UnitEngine) DumpProcessStats(statsid, hostname string, cpuusage float64, rss int) {
+	e.DumpSamples("legacy", samples.Batch{Samples: []samples.Sample{{
+		Host:      hostname,
+		Process:   statsid,
+		Timestamp: e.clock.Now(),
+		Metrics: map[string]float64{
+			samples.CPUUsage: cpuusage,
+			samples.RSS:      float64(rss),
+		},
+	}}})
+}
+
+// secondsSinceStart returns the seconds elapsed between the start of the benchmark
+// and t, negative for the samples taken before
+func (e *UnitEngine) secondsSinceStart(t time.Time) float64 {
+	if e.startTime.IsZero() {
+		return 0
+	}
+	return t.Sub(e.startTime).Seconds()
+}
+
+// metricLines returns the samples of all the sources, and the process and the
+// host stats of every machine extracted from them
+func (e *UnitEngine) metricLines() ([]metricLine, map[string][]processStatsLine, map[string][]hostStatsLine) {
+	lines := []metricLine{}
+	machineStats := map[string][]processStatsLine{}
+	hostStats := map[string][]hostStatsLine{}
+
+	for _, s := range e.samples {
+		timestamp := e.secondsSinceStart(s.sample.Timestamp)
+		metrics := s.sample.Metrics
+		lines = append(lines, metricLine{
+			Source:    s.source,
+			Host:      s.sample.Host,
+			Process:   s.sample.Process,
+			TimeStamp: timestamp,
+			Metrics:   metrics,
+		})
+
+		if s.sample.Process == "" {
+			hostStats[s.sample.Host] = append(hostStats[s.sample.Host], hostStatsLine{
+				TimeStamp:    timestamp,
+				Load1:        metrics[samples.Load1],
+				Load5:        metrics[samples.Load5],
+				Load15:       metrics[samples.Load15],
+				MemTotal:     int(metrics[samples.MemTotal]),
+				MemAvailable: int(metrics[samples.MemAvailable]),
+				SystemdUnits: int(metrics[samples.SystemdUnits]),
+			})
+			continue
+		}
+
+		machineStats[s.sample.Host] = append(machineStats[s.sample.Host], e.processStatsLineOf(s))
+	}
+	return lines, machineStats, hostStats
+}
+
+func (e *UnitEngine) processStatsLineOf(s metricSample) processStatsLine {
+	metrics := s.sample.Metrics
+	return processStatsLine{
+		Process:                 s.sample.Process,
+		TimeStamp:               e.secondsSinceStart(s.sample.Timestamp),
+		CPUUsage:                metrics[samples.CPUUsage],
+		RSS:                     int(metrics[samples.RSS]),
+		Threads:                 int(metrics[samples.Threads]),
+		FDs:                     int(metrics[samples.FDs]),
+		VoluntaryCtxSwitches:    int64(metrics[samples.VoluntaryCtxSwitches]),
+		NonvoluntaryCtxSwitches: int64(metrics[samples.NonvoluntaryCtxSwitches]),
+	}
+}
@@ -0,0 +1,42 @@
+package unit
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/nomi/definition"
+	"github.com/giantswarm/nomi/samples"
+)
+
+func TestDumpSamples(t *testing.T) {
+	def, _ := definition.BenchmarkDefByRawInstructions("(start 1 0)", 1)
+	engine, _ := NewEngine(def, false)
+	engine.startTime = time.Now()
+
+	engine.DumpSamples("agent", samples.Batch{Samples: []samples.Sample{
+		{Host: "h1", Timestamp: engine.startTime.Add(2 * time.Second), Metrics: map[string]float64{samples.Load1: 0.5, samples.MemTotal: 1024}},
+		{Host: "h1", Process: "fleetd", Timestamp: engine.startTime.Add(2 * time.Second), Metrics: map[string]float64{samples.CPUUsage: 12.5, samples.RSS: 2048, "io_read_bytes": 10}},
+	}})
+	engine.DumpProcessStats("etcd", "h2", 3.5, 512)
+
+	stats := engine.Stats()
+	if len(stats.Samples) != 3 || stats.Samples[1].Source != "agent" || stats.Samples[1].TimeStamp != 2 ||
+		stats.Samples[1].Metrics["io_read_bytes"] != 10 || stats.Samples[2].Source != "legacy" {
+		log.Fatalf("wrong samples, got: %+v", stats.Samples)
+	}
+
+	host := stats.HostStats["h1"]
+	if len(host) != 1 || host[0].Load1 != 0.5 || host[0].MemTotal != 1024 || len(stats.HostStats) != 1 {
+		log.Fatalf("wrong host stats, got: %+v", stats.HostStats)
+	}
+
+	fleetd := stats.MachineStats["h1"]
+	if len(fleetd) != 1 || fleetd[0].Process != "fleetd" || fleetd[0].CPUUsage != 12.5 || fleetd[0].RSS != 2048 {
+		log.Fatalf("wrong fleetd stats, got: %+v", stats.MachineStats)
+	}
+	etcd := stats.MachineStats["h2"]
+	if len(etcd) != 1 || etcd[0].Process != "etcd" || etcd[0].CPUUsage != 3.5 || etcd[0].RSS != 512 {
+		log.Fatalf("wrong legacy stats, got: %+v", stats.MachineStats)
+	}
+}
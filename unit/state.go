@@ -8,4 +8,8 @@ type UnitState struct {
 	actualStartTime  time.Time
 	stopRequestTime  time.Time
 	actualStopTime   time.Time
+
+	// Placement of the instance group reported by its units
+	hostname  string
+	machineID string
 }
@@ -0,0 +1,194 @@
+package unit
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/giantswarm/nomi/definition"
+)
+
+// statusWindow is the number of the most recent operations the percentiles of
+// the status are computed over
+const statusWindow = 100
+
+type instructionStatus struct {
+	Index       int
+	Total       int
+	Description string
+}
+
+type unitCounts struct {
+	Starting int
+	Running  int
+	Stopping int
+	Stopped  int
+	Failed   int
+}
+
+type delayPercentiles struct {
+	Count int
+	P50   float64
+	P90   float64
+	P99   float64
+	Max   float64
+}
+
+// machineStatus holds the metrics of the last samples of a machine and of its
+// processes
+type machineStatus struct {
+	Hostname  string
+	TimeStamp float64
+	Host      map[string]float64
+	Processes map[string]map[string]float64
+}
+
+// Status is a snapshot of a benchmark in progress
+type Status struct {
+	Elapsed     float64
+	Finished    bool
+	Paused      bool
+	Aborted     bool
+	Queued      int
+	Instruction instructionStatus
+	Units       unitCounts
+	StartDelay  delayPercentiles
+	StopDelay   delayPercentiles
+	Machines    []machineStatus
+}
+
+// enterInstruction records the instruction the benchmark is running
+func (e *UnitEngine) enterInstruction(index int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.currentInstruction = index
+}
+
+// Status returns the state of the benchmark, the percentiles of the delays of
+// the last operations and the last samples of every machine
+func (e *UnitEngine) Status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	instructions := e.benchmark.Instructions
+	status := Status{
+		Elapsed:  e.secondsSinceStart(e.clock.Now()),
+		Finished: e.currentInstruction >= len(instructions),
+		Paused:   e.paused,
+		Aborted:  e.aborted,
+		Queued:   len(e.queued),
+		Instruction: instructionStatus{
+			Index: e.currentInstruction,
+			Total: len(instructions),
+		},
+		Units: unitCounts{
+			Starting: len(e.startingUnits),
+			Running:  len(e.runningUnits),
+			Stopping: len(e.stoppingUnits),
+			Stopped:  len(e.stoppedUnits),
+			Failed:   len(e.failedStats),
+		},
+		StartDelay: percentilesOf(lastDelays(e.startedStats, statusWindow)),
+		StopDelay:  percentilesOf(lastDelays(e.stoppedStats, statusWindow)),
+		Machines:   e.lastMachineSamples(),
+	}
+	if e.currentInstruction >= 0 && e.currentInstruction < len(instructions) {
+		status.Instruction.Description = describeInstruction(instructions[e.currentInstruction])
+	}
+	return status
+}
+
+// describeInstruction returns an instruction the way it is written with
+// --raw-instructions
+func describeInstruction(instruction definition.Instruction) string {
+	var (
+		emptyStart         definition.Start
+		emptyFloat         definition.Float
+		emptyExpectRunning definition.ExpectRunning
+	)
+	switch {
+	case instruction.Start != emptyStart:
+		return fmt.Sprintf("start %d %d", instruction.Start.Max, instruction.Start.Interval)
+	case instruction.Float != emptyFloat:
+		return fmt.Sprintf("float %v %d", instruction.Float.Rate, instruction.Float.Duration)
+	case instruction.Sleep != 0:
+		return fmt.Sprintf("sleep %d", instruction.Sleep)
+	case instruction.ExpectRunning != emptyExpectRunning:
+		return fmt.Sprintf("expect-running %s %d", instruction.ExpectRunning.Symbol, instruction.ExpectRunning.Amount)
+	case instruction.Stop != "":
+		return string(instruction.Stop)
+	}
+	return ""
+}
+
+func lastDelays(lines stats, n int) []float64 {
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	delays := make([]float64, 0, len(lines))
+	for _, line := range lines {
+		delays = append(delays, line.Delay)
+	}
+	return delays
+}
+
+func percentilesOf(delays []float64) delayPercentiles {
+	if len(delays) == 0 {
+		return delayPercentiles{}
+	}
+	sorted := append([]float64{}, delays...)
+	sort.Float64s(sorted)
+	return delayPercentiles{
+		Count: len(sorted),
+		P50:   Percentile(sorted, 50),
+		P90:   Percentile(sorted, 90),
+		P99:   Percentile(sorted, 99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// Percentile returns the percentile p of sorted values, interpolated linearly
+// between the closest ranks. The live status and the final report both use it
+// so that they agree on the same delays.
+func Percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(rank)
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (rank-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+func (e *UnitEngine) lastMachineSamples() []machineStatus {
+	machines := map[string]*machineStatus{}
+	for _, s := range e.samples {
+		machine, exists := machines[s.sample.Host]
+		if !exists {
+			machine = &machineStatus{
+				Hostname:  s.sample.Host,
+				Host:      map[string]float64{},
+				Processes: map[string]map[string]float64{},
+			}
+			machines[s.sample.Host] = machine
+		}
+		if timestamp := e.secondsSinceStart(s.sample.Timestamp); timestamp > machine.TimeStamp {
+			machine.TimeStamp = timestamp
+		}
+		if s.sample.Process == "" {
+			machine.Host = s.sample.Metrics
+		} else {
+			machine.Processes[s.sample.Process] = s.sample.Metrics
+		}
+	}
+
+	hostnames := []string{}
+	for hostname := range machines {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+
+	status := []machineStatus{}
+	for _, hostname := range hostnames {
+		status = append(status, *machines[hostname])
+	}
+	return status
+}
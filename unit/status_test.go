@@ -0,0 +1,65 @@
+package unit
+
+import (
+	"bytes"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/nomi/definition"
+	"github.com/giantswarm/nomi/samples"
+)
+
+func TestStatus(t *testing.T) {
+	def, _ := definition.BenchmarkDefByRawInstructions("(start 3 100) (sleep 10) (stop-all)", 1)
+	engine, _ := NewEngine(def, false)
+	engine.startTime = time.Now()
+	engine.enterInstruction(1)
+
+	for i, delay := range []float64{3, 1, 2, 0.2} {
+		engine.startedStats = append(engine.startedStats, statsLine{ID: strconv.Itoa(i), Delay: delay})
+	}
+	engine.runningUnits["a"] = UnitState{}
+	engine.startingUnits["b"] = UnitState{}
+	engine.DumpSamples("agent", samples.Batch{Samples: []samples.Sample{
+		{Host: "core-1", Timestamp: engine.startTime.Add(time.Second), Metrics: map[string]float64{samples.Load1: 0.5}},
+		{Host: "core-1", Process: "fleetd", Timestamp: engine.startTime.Add(time.Second), Metrics: map[string]float64{samples.CPUUsage: 4}},
+		{Host: "core-1", Timestamp: engine.startTime.Add(2 * time.Second), Metrics: map[string]float64{samples.Load1: 0.75}},
+	}})
+
+	status := engine.Status()
+	if status.Finished || status.Instruction.Index != 1 || status.Instruction.Total != 3 || status.Instruction.Description != "sleep 10" {
+		log.Fatalf("wrong instruction, got: %+v", status.Instruction)
+	}
+	if status.Units.Running != 1 || status.Units.Starting != 1 || status.Units.Stopped != 0 {
+		log.Fatalf("wrong unit counts, got: %+v", status.Units)
+	}
+	if status.StartDelay.Count != 4 || status.StartDelay.P50 != 1.5 || math.Abs(status.StartDelay.P90-2.7) > 1e-9 || status.StartDelay.Max != 3 {
+		log.Fatalf("wrong start delay percentiles, got: %+v", status.StartDelay)
+	}
+	if len(status.Machines) != 1 || status.Machines[0].Host[samples.Load1] != 0.75 || status.Machines[0].TimeStamp != 2 ||
+		status.Machines[0].Processes["fleetd"][samples.CPUUsage] != 4 {
+		log.Fatalf("wrong machine samples, got: %+v", status.Machines)
+	}
+
+	out := new(bytes.Buffer)
+	engine.WritePrometheus(out)
+	for _, expected := range []string{
+		"# TYPE nomi_units gauge\n",
+		"nomi_units{state=\"running\"} 1\n",
+		"nomi_instruction 1\n",
+		"nomi_start_delay_seconds_bucket{le=\"0.25\"} 1\n",
+		"nomi_start_delay_seconds_bucket{le=\"+Inf\"} 4\n",
+		"nomi_start_delay_seconds_sum 6.2\n",
+		"nomi_start_delay_window_seconds{quantile=\"0.5\"} 1.5\n",
+		"nomi_host_load1{host=\"core-1\"} 0.75\n",
+		"nomi_process_cpu_usage{host=\"core-1\",process=\"fleetd\"} 4\n",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			log.Fatalf("expected %q in the Prometheus output, got:\n%s", expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package unit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/giantswarm/nomi/definition"
+)
+
+// TimelineEntry describes an operation of the benchmark and when Run triggers
+// it relative to the start of the benchmark
+type TimelineEntry struct {
+	Start time.Duration
+	End   time.Duration
+	// AfterWait is set when the entry follows an expect-running instruction,
+	// so its times are lower bounds
+	AfterWait   bool
+	Cmd         string
+	Description string
+}
+
+// Timeline expands the instructions of the benchmark into the operations Run
+// performs, without executing them. Start instructions spawn their units in the
+// background while the next instructions are already being processed.
+func (e *UnitEngine) Timeline() []TimelineEntry {
+	var (
+		emptyStart         definition.Start
+		emptyFloat         definition.Float
+		emptyExpectRunning definition.ExpectRunning
+		now                time.Duration
+		afterWait          bool
+	)
+	timeline := []TimelineEntry{}
+	add := func(cmd string, duration time.Duration, description string) {
+		timeline = append(timeline, TimelineEntry{
+			Start:       now,
+			End:         now + duration,
+			AfterWait:   afterWait,
+			Cmd:         cmd,
+			Description: description,
+		})
+	}
+
+	for _, instruction := range e.benchmark.Instructions {
+		if instruction.Start != emptyStart {
+			interval := time.Duration(instruction.Start.Interval) * time.Millisecond
+			// start waits the interval after every spawn, the last one included
+			duration := time.Duration(instruction.Start.Max) * interval
+			add("start", duration, fmt.Sprintf("spawns %d instance groups of %d units (%d units), one every %v, in the background",
+				instruction.Start.Max, e.InstanceGroupSize(), instruction.Start.Max*e.InstanceGroupSize(), interval))
+		}
+		if instruction.Float != emptyFloat {
+			add("float", 0, "not implemented yet, does nothing")
+		}
+		if instruction.Sleep != 0 {
+			duration := time.Duration(instruction.Sleep) * time.Second
+			add("sleep", duration, fmt.Sprintf("waits %v", duration))
+			now += duration
+		}
+		if instruction.ExpectRunning != emptyExpectRunning {
+			add("expect-running", 0, fmt.Sprintf("waits until the number of running units is %s %d",
+				instruction.ExpectRunning.Symbol, instruction.ExpectRunning.Amount))
+			afterWait = true
+		}
+		if instruction.Stop != "" {
+			add("stop-all", 0, "stops all the starting and running units")
+		}
+	}
+	add("end", 0, "stops the remaining units and finishes the benchmark")
+
+	return timeline
+}
@@ -0,0 +1,36 @@
+package unit
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/nomi/definition"
+)
+
+func TestTimeline(t *testing.T) {
+	def, _ := definition.BenchmarkDefByRawInstructions("(start 4 500) (sleep 2) (expect-running > 3) (stop-all)", 2)
+	engine, _ := NewEngine(def, false)
+
+	timeline := engine.Timeline()
+	expected := []struct {
+		cmd        string
+		start, end time.Duration
+		afterWait  bool
+	}{
+		{"start", 0, 2 * time.Second, false},
+		{"sleep", 0, 2 * time.Second, false},
+		{"expect-running", 2 * time.Second, 2 * time.Second, false},
+		{"stop-all", 2 * time.Second, 2 * time.Second, true},
+		{"end", 2 * time.Second, 2 * time.Second, true},
+	}
+	if len(timeline) != len(expected) {
+		log.Fatalf("expected %d entries, got: %+v", len(expected), timeline)
+	}
+	for i, e := range expected {
+		entry := timeline[i]
+		if entry.Cmd != e.cmd || entry.Start != e.start || entry.End != e.end || entry.AfterWait != e.afterWait {
+			log.Fatalf("entry %d: expected %+v, got: %+v", i, e, entry)
+		}
+	}
+}